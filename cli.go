@@ -0,0 +1,138 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is one s3bench subcommand. Each parses its own arguments with its
+// own flag set; the connection, credential, TLS and retry flags are shared
+// through registerConnectionFlags.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands lists the subcommands in the order shown by "s3bench help".
+func commands() []command {
+	return []command{
+		{modeDownload, "Benchmark ranged GETs of one or more objects (default)", func(args []string) { runBenchmark(modeDownload, args) }},
+		{modeUpload, "Benchmark a multipart upload of a generated payload or a file", func(args []string) { runBenchmark(modeUpload, args) }},
+		{modeMixed, "Benchmark a mix of small-object GET, PUT, HEAD, DELETE and LIST operations", func(args []string) { runBenchmark(modeMixed, args) }},
+		{modePrepare, "Create generated test objects under a prefix", runPrepare},
+		{"cleanup", "Delete the objects under a prefix that s3bench wrote", runCleanup},
+		{"compare", "Compare the --json results of two benchmark runs and flag regressions", runCompare},
+		{"histogram", "Merge latency histograms exported with --histogram-file and report their percentiles", runHistogram},
+		{"run", "Run the stages of a scenario file and report them together", runScenario},
+	}
+}
+
+// dispatch picks the subcommand from the command line. Without one, the
+// arguments go to download, so invocations from before subcommands existed
+// keep working; their --mode flag is translated into the matching command.
+func dispatch(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		name, rest := modeDownload, args
+		if mode, stripped, ok := legacyMode(args); ok {
+			fmt.Fprintf(os.Stderr, "note: --mode is deprecated; use \"s3bench %s [flags]\"\n", mode)
+			name, rest = mode, stripped
+		}
+		args = append([]string{name}, rest...)
+	}
+
+	name := args[0]
+	if name == "help" {
+		if len(args) > 1 {
+			// Every command prints its usage and exits on -h.
+			dispatch([]string{args[1], "-h"})
+			return
+		}
+		printUsage(os.Stdout)
+		return
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			cmd.run(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "error: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(1)
+}
+
+// legacyMode finds a --mode flag in args and returns its value and the
+// arguments without it.
+func legacyMode(args []string) (mode string, rest []string, ok bool) {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "mode" {
+			continue
+		}
+		rest = append(rest, args[:i]...)
+		if hasValue {
+			return value, append(rest, args[i+1:]...), true
+		}
+		if i+1 < len(args) {
+			return args[i+1], append(rest, args[i+2:]...), true
+		}
+		return "", nil, false
+	}
+	return "", nil, false
+}
+
+// printUsage lists the subcommands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: s3bench [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nWithout a command, s3bench runs download. Run \"s3bench help COMMAND\" for the flags of a command.\n")
+}
+
+// newFlagSet returns the flag set for a command. Parse errors are returned
+// rather than printed, so the same parser serves the command line, where
+// usageError reports them, and scenario files, which are checked in full
+// before their first stage runs.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = commandUsage(fs, name)
+	return fs
+}
+
+// commandUsage returns the usage function for a command's flag set.
+func commandUsage(fs *flag.FlagSet, name string) func() {
+	return func() {
+		summary := ""
+		for _, cmd := range commands() {
+			if cmd.name == name {
+				summary = cmd.summary
+			}
+		}
+		fmt.Fprintf(fs.Output(), "Usage: s3bench %s [flags]\n\n%s.\n\nFlags:\n", name, summary)
+		fs.PrintDefaults()
+	}
+}
+
+// usageError reports a command-line error followed by the command's usage
+// and exits. A request for help (-h) prints the usage alone.
+func usageError(fs *flag.FlagSet, err error) {
+	fs.SetOutput(os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		fs.Usage()
+		os.Exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "error: %v\n\n", err)
+	fs.Usage()
+	os.Exit(1)
+}
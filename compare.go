@@ -0,0 +1,290 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exitRegression is the exit status of compare and --baseline when the
+// candidate fails the thresholds, so a CI pipeline can tell a slower run from
+// a broken one, which exits with status 1.
+const exitRegression = 2
+
+// runCompare implements "s3bench compare": it reads the --json output of two
+// benchmark runs and compares them cell by cell, matching chunk size and
+// concurrency. It exits with exitRegression when a change crosses a --max-*
+// threshold or a cell of the baseline is missing, so it can gate a CI
+// pipeline.
+func runCompare(args []string) {
+	fs := newFlagSet("compare")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: s3bench compare [flags] BASELINE.json CANDIDATE.json\n\nCompare the --json results of two benchmark runs.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	var limits RegressionThresholds
+	limits.register(fs)
+	if err := fs.Parse(args); err != nil {
+		usageError(fs, err)
+	}
+	if fs.NArg() != 2 {
+		usageError(fs, fmt.Errorf("compare takes two result files"))
+	}
+	if err := limits.validate(); err != nil {
+		usageError(fs, err)
+	}
+
+	baseline, baseOp, err := readSweeps(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	candidate, candOp, err := readSweeps(fs.Arg(1))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if baseOp != candOp {
+		log.Fatalf("cannot compare %s results (%s) with %s results (%s)", candOp, fs.Arg(1), baseOp, fs.Arg(0))
+	}
+
+	fmt.Printf("Comparing %s (candidate) with %s (baseline)\n", fs.Arg(1), fs.Arg(0))
+	if compareSweeps(os.Stdout, baseline, candidate, limits) > 0 {
+		os.Exit(exitRegression)
+	}
+}
+
+// readSweeps loads the sweeps from a --json result file, whether written by a
+// normal run or by --auto-tune, and the operation they measured. Every run in
+// the file must be of that operation.
+func readSweeps(path string) ([]ConcurrencySweep, string, error) {
+	res, err := readResult(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sweeps := res.benchmarkSweeps()
+	if res.Scenario != nil {
+		return nil, "", fmt.Errorf("%s: is a scenario report; compare the --json results of single benchmark runs", path)
+	}
+	if len(sweeps) == 0 {
+		return nil, "", fmt.Errorf("%s: contains no results", path)
+	}
+	for _, sw := range sweeps {
+		for _, s := range append(sw.Warmup, sw.Summaries...) {
+			if s.Operation != res.Command {
+				return nil, "", fmt.Errorf("%s: holds %s runs in %s results", path, s.Operation, res.Command)
+			}
+		}
+	}
+	return sweeps, res.Command, nil
+}
+
+// RegressionThresholds are the largest changes from baseline to candidate, in
+// percent, that still pass. Zero disables a check.
+type RegressionThresholds struct {
+	ThroughputDrop float64
+	P50Increase    float64
+	P99Increase    float64
+	TTFBIncrease   float64
+}
+
+// register adds the --max-* threshold flags to fs.
+func (t *RegressionThresholds) register(fs *flag.FlagSet) {
+	fs.Float64Var(&t.ThroughputDrop, "max-throughput-drop", 0, "Fail when mean throughput (ops/s for mixed) drops by more than this many percent (0 = no limit)")
+	fs.Float64Var(&t.P50Increase, "max-p50-increase", 0, "Fail when P50 latency rises by more than this many percent (0 = no limit)")
+	fs.Float64Var(&t.P99Increase, "max-p99-increase", 0, "Fail when P99 latency rises by more than this many percent (0 = no limit)")
+	fs.Float64Var(&t.TTFBIncrease, "max-ttfb-increase", 0, "Fail when mean TTFB rises by more than this many percent (0 = no limit)")
+}
+
+// set reports whether any threshold is enabled.
+func (t RegressionThresholds) set() bool {
+	return t != RegressionThresholds{}
+}
+
+// validate rejects negative thresholds.
+func (t RegressionThresholds) validate() error {
+	if t.ThroughputDrop < 0 || t.P50Increase < 0 || t.P99Increase < 0 || t.TTFBIncrease < 0 {
+		return fmt.Errorf("--max-* thresholds must not be negative")
+	}
+	return nil
+}
+
+// sweepCell identifies one cell of the chunk size × concurrency grid.
+type sweepCell struct {
+	chunkSize   int64
+	concurrency int
+}
+
+// compareMetric is one row of the comparison of a cell.
+type compareMetric struct {
+	name         string
+	value        func(ConcurrencySweep) float64
+	format       func(float64) string
+	limit        float64 // largest allowed change in percent, 0 = no limit
+	lowerIsWorse bool
+}
+
+// regressed reports whether a change in percent crosses the metric's limit.
+func (m compareMetric) regressed(change float64) bool {
+	if m.limit == 0 {
+		return false
+	}
+	if m.lowerIsWorse {
+		return change < -m.limit
+	}
+	return change > m.limit
+}
+
+// compareMetrics returns the metrics compared for sweeps like baseline.
+func compareMetrics(baseline []ConcurrencySweep, limits RegressionThresholds) []compareMetric {
+	unit, metric := comparisonMetric(baseline)
+	return []compareMetric{
+		{
+			name:         "Throughput",
+			value:        func(sw ConcurrencySweep) float64 { _, mean, _ := metric(sw.Aggregate); return mean },
+			format:       func(v float64) string { return fmt.Sprintf("%.1f %s", v, unit) },
+			limit:        limits.ThroughputDrop,
+			lowerIsWorse: true,
+		},
+		{
+			name:   "P50 latency",
+			value:  func(sw ConcurrencySweep) float64 { return float64(sweepLatency(sw).P50) },
+			format: func(v float64) string { return formatDuration(time.Duration(v)) },
+			limit:  limits.P50Increase,
+		},
+		{
+			name:   "P99 latency",
+			value:  func(sw ConcurrencySweep) float64 { return float64(sweepLatency(sw).P99) },
+			format: func(v float64) string { return formatDuration(time.Duration(v)) },
+			limit:  limits.P99Increase,
+		},
+		{
+			name:   "Mean TTFB",
+			value:  func(sw ConcurrencySweep) float64 { return float64(meanTTFB(sw.Summaries)) },
+			format: func(v float64) string { return formatDuration(time.Duration(v)) },
+			limit:  limits.TTFBIncrease,
+		},
+	}
+}
+
+// sweepLatency returns the chunk latency of a cell: that of all its runs
+// merged when available, otherwise the worst of the runs.
+func sweepLatency(sw ConcurrencySweep) LatencyStats {
+	if sw.Aggregate.Latency != nil {
+		return *sw.Aggregate.Latency
+	}
+	var worst LatencyStats
+	for _, s := range sw.Summaries {
+		worst.P50 = max(worst.P50, s.ChunkLatency.P50)
+		worst.P99 = max(worst.P99, s.ChunkLatency.P99)
+	}
+	return worst
+}
+
+// meanTTFB is the mean TTFB of every successful chunk of runs.
+func meanTTFB(summaries []RunSummary) time.Duration {
+	var total time.Duration
+	var chunks int
+	for _, s := range summaries {
+		total += time.Duration(s.MeanTTFB) * time.Duration(s.ChunkCount)
+		chunks += s.ChunkCount
+	}
+	if chunks == 0 {
+		return 0
+	}
+	return total / time.Duration(chunks)
+}
+
+// compareSweeps prints throughput, P50 and P99 latency and TTFB for every cell
+// of either result, with the change from baseline to candidate, and returns
+// the number of failures: changes beyond limits and, when any limit is set,
+// baseline cells the candidate did not run. Cells run by only one side are
+// listed with a dash for the other; new cells in the candidate always pass.
+func compareSweeps(w io.Writer, baseline, candidate []ConcurrencySweep, limits RegressionThresholds) int {
+	metrics := compareMetrics(baseline, limits)
+
+	var order []sweepCell
+	base := map[sweepCell]ConcurrencySweep{}
+	cand := map[sweepCell]ConcurrencySweep{}
+	for _, side := range []struct {
+		sweeps []ConcurrencySweep
+		into   map[sweepCell]ConcurrencySweep
+	}{{baseline, base}, {candidate, cand}} {
+		for _, sw := range side.sweeps {
+			cell := sweepCell{sw.ChunkSize, sw.Concurrency}
+			if _, seen := base[cell]; !seen {
+				if _, seen := cand[cell]; !seen {
+					order = append(order, cell)
+				}
+			}
+			side.into[cell] = sw
+		}
+	}
+
+	fmt.Fprintf(w, "\n  %-10s  %7s  %-11s  %12s  %12s  %8s\n",
+		"Chunk size", "Workers", "Metric", "Baseline", "Candidate", "Change")
+	fmt.Fprintf(w, "  %-10s  %7s  %-11s  %12s  %12s  %8s\n",
+		"----------", "-------", "------", "--------", "---------", "------")
+	regressions, missing := 0, 0
+	for _, cell := range order {
+		b, inBase := base[cell]
+		c, inCand := cand[cell]
+		chunk := "—"
+		if cell.chunkSize > 0 {
+			chunk = formatBytes(cell.chunkSize)
+		}
+		workers := strconv.Itoa(cell.concurrency)
+		for i, m := range metrics {
+			baseText, candText, change, verdict := "—", "—", "", ""
+			if i == 0 && inBase && !inCand && limits.set() {
+				missing++
+				verdict = "   MISSING from candidate"
+			}
+			var baseValue, candValue float64
+			if inBase {
+				baseValue = m.value(b)
+				baseText = m.format(baseValue)
+			}
+			if inCand {
+				candValue = m.value(c)
+				candText = m.format(candValue)
+			}
+			if inBase && inCand && baseValue > 0 {
+				pct := (candValue - baseValue) / baseValue * 100
+				change = fmt.Sprintf("%+.1f%%", pct)
+				if m.regressed(pct) {
+					regressions++
+					sign := "+"
+					if m.lowerIsWorse {
+						sign = "-"
+					}
+					verdict = fmt.Sprintf("   REGRESSION (limit %s%g%%)", sign, m.limit)
+				}
+			}
+			line := fmt.Sprintf("  %-10s  %7s  %-11s  %12s  %12s  %8s%s",
+				chunk, workers, m.name, baseText, candText, change, verdict)
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+			chunk, workers = "", ""
+		}
+	}
+
+	if limits.set() {
+		switch {
+		case regressions == 0 && missing == 0:
+			fmt.Fprintf(w, "\n  No regressions beyond the thresholds.\n")
+		case missing == 0:
+			fmt.Fprintf(w, "\n  %s beyond the thresholds.\n", plural(regressions, "regression"))
+		default:
+			fmt.Fprintf(w, "\n  %s beyond the thresholds; %s of the baseline missing from the candidate.\n",
+				plural(regressions, "regression"), plural(missing, "cell"))
+		}
+	}
+	return regressions + missing
+}
@@ -7,53 +7,240 @@ package main
 import (
 	"flag"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all runtime configuration parsed from CLI flags.
 type Config struct {
-	Endpoint        string
-	Bucket          string
-	Key             string
-	Region          string
-	Profile         string
-	AccessKeyID     string
-	SecretAccessKey string
-	ChunkSize       int64
-	ConcurrencyList []int
-	Runs            int
-	DiscardOutput   bool
-	OutputFile      string
-	JSONOutput      bool
+	Mode             string
+	Endpoint         string
+	Bucket           string
+	Key              string
+	Prefix           string
+	Manifest         string
+	Region           string
+	Profile          string
+	AccessKeyID      string
+	SecretAccessKey  string
+	ChunkSize        int64 // chunk size of the grid cell being run; the first of ChunkSizeList until then
+	ChunkSizeList    []int64
+	ConcurrencyList  []int
+	Runs             int
+	WarmupRuns       int           // runs per grid cell before measurement, excluded from results
+	WarmupDuration   time.Duration // alternative to WarmupRuns: warm up for this long
+	DiscardOutput    bool
+	OutputFile       string
+	Format           string        // results format: text, json, csv or tsv
+	Quiet            bool          // no text output while running; set for every format but text
+	ChunksFile       string        // write one row per chunk request to this CSV or TSV file
+	TraceFile        string        // write a trace of every chunk request to this file
+	TraceFormat      string        // format of TraceFile: jsonl or chrome
+	TimelineInterval time.Duration // sample live counters this often during each run (0 = off)
+	TimelineFile     string        // also write the samples to this CSV file
+	Percentiles      []float64     // latency percentiles reported for chunks and operations
+	HistogramFile    string        // export the latency histograms of each grid cell here
+	HTMLReport       string        // write a self-contained HTML report with charts here
+	UploadFile       string
+	UploadSize       int64
+	Seed             int64
+	Compressibility  float64 // generated payloads compress by about this ratio (1 = incompressible)
+	Duration         time.Duration
+	RandomRanges     bool
+	OpMix            []OpWeight
+	ObjectSize       int64
+	Ops              int
+	SeedObjects      int
+	Transport        TransportSettings
+	Retry            RetryPolicy
+	ErrorBudget      ErrorBudget
+	Verify           bool
+	AutoTune         bool
+	TuneMinGain      float64 // percent
+	TuneMaxP99       time.Duration
+	TuneMaxConc      int
+	MetricsListen    string            // serve Prometheus metrics on this address while running
+	Tags             map[string]string // --tags, recorded in the results and sent with them
+	PushInflux       string            // InfluxDB write URL to send the results to
+	PushGateway      string            // Pushgateway URL to send the results to
+	PushJob          string            // Pushgateway job name
+	PushWebhook      string            // URL to POST the JSON results to
+	PushTimeout      time.Duration
+	Baseline         string               // --json result file to compare the results with
+	Thresholds       RegressionThresholds // --max-* limits on the changes from Baseline
+	PrepareObjects   []prepareBatch       // prepare: the objects to create
+	DryRun           bool                 // cleanup: list what would be deleted without deleting it
 }
 
-func parseConfig() (*Config, error) {
-	var rawChunkSize string
-	cfg := &Config{}
-
-	flag.StringVar(&cfg.Endpoint, "endpoint", "", "S3-compatible endpoint URL (empty = AWS)")
-	flag.StringVar(&cfg.Bucket, "bucket", "", "S3 bucket name (required)")
-	flag.StringVar(&cfg.Key, "key", "", "S3 object key (required)")
-	flag.StringVar(&cfg.Region, "region", "us-east-1", "AWS region")
-	flag.StringVar(&cfg.Profile, "profile", "impossible", "AWS named profile from ~/.aws/credentials or ~/.aws/config")
-	flag.StringVar(&cfg.AccessKeyID, "access-key-id", "", "AWS access key ID (overrides profile)")
-	flag.StringVar(&cfg.SecretAccessKey, "secret-access-key", "", "AWS secret access key (overrides profile)")
-	flag.StringVar(&rawChunkSize, "chunk-size", "64MB", "Chunk size for byte-range reads (e.g. 64MB, 1GB) or named preset: XS=1MB S=4MB M=8MB L=64MB XL=256MB XXL=1GB")
-	var rawConcurrency string
-	flag.StringVar(&rawConcurrency, "concurrency", "8", "Parallel download workers — single value or comma-separated list for a sweep (e.g. 8 or 8,16,32)")
-	flag.IntVar(&cfg.Runs, "runs", 1, "Number of benchmark runs")
-	flag.BoolVar(&cfg.DiscardOutput, "discard", false, "Discard downloaded bytes (benchmark mode, no file write)")
-	flag.StringVar(&cfg.OutputFile, "output", "", "Write downloaded object to this file path")
-	flag.BoolVar(&cfg.JSONOutput, "json", false, "Emit results as JSON")
-	flag.Parse()
+// Benchmark modes, each run by the command of the same name.
+const (
+	modeDownload = "download"
+	modeUpload   = "upload"
+	modeMixed    = "mixed"
+)
 
-	if cfg.Bucket == "" {
-		return nil, fmt.Errorf("--bucket is required")
+// Results formats for --format.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatTSV  = "tsv"
+)
+
+// Trace formats for --trace-format.
+const (
+	traceJSONL  = "jsonl"
+	traceChrome = "chrome"
+)
+
+// defaultPercentiles are the latency percentiles reported without --percentiles.
+const defaultPercentiles = "50,95,99,99.9"
+
+// defaultOpsPrefix is the key prefix the mixed workload works under when --prefix is not set.
+const defaultOpsPrefix = "s3bench-ops/"
+
+// parseConfig parses the flags of the download, upload and mixed commands.
+// Each command registers only the flags that apply to it, on top of the shared
+// connection flags. The flag set is returned so callers can print its usage.
+func parseConfig(mode string, args []string) (*Config, *flag.FlagSet, error) {
+	var rawChunkSize, rawConcurrency, rawUploadSize, rawObjectSize, rawOpMix, rawPercentiles, rawTags string
+	var jsonOutput bool
+	cfg := &Config{Mode: mode}
+	fs := newFlagSet(mode)
+	conn := registerConnectionFlags(fs, cfg)
+
+	switch mode {
+	case modeDownload:
+		fs.StringVar(&cfg.Key, "key", "", "S3 object key (one of --key, --prefix or --manifest is required)")
+		fs.StringVar(&cfg.Prefix, "prefix", "", "Read every object under this key prefix (expanded with ListObjectsV2)")
+		fs.StringVar(&cfg.Manifest, "manifest", "", "Read the objects listed in this file, one key per line")
+		fs.StringVar(&rawChunkSize, "chunk-size", "64MB", "Chunk size for byte-range reads (e.g. 64MB, 1GB) or named preset: XS=1MB S=4MB M=8MB L=64MB XL=256MB XXL=1GB — single value or comma-separated list for a sweep (e.g. 8MB,64MB,L)")
+		fs.BoolVar(&cfg.DiscardOutput, "discard", false, "Discard downloaded bytes (benchmark mode, no file write)")
+		fs.StringVar(&cfg.OutputFile, "output", "", "Write downloaded object to this file path")
+		fs.DurationVar(&cfg.Duration, "duration", 0, "Keep re-reading the object until this time budget expires (e.g. 30s, 5m); 0 = read it once")
+		fs.BoolVar(&cfg.RandomRanges, "random-ranges", false, "With --duration, read chunks in random order instead of cycling through the object")
+		fs.BoolVar(&cfg.Verify, "verify", false, "Check the data against the s3bench pattern, the object's S3 checksum or its ETag")
+	case modeUpload:
+		fs.StringVar(&cfg.Key, "key", "", "S3 object key to upload to (required)")
+		fs.StringVar(&rawChunkSize, "chunk-size", "64MB", "Multipart part size (e.g. 64MB) or named preset — single value or comma-separated list for a sweep")
+		fs.StringVar(&cfg.UploadFile, "upload-file", "", "Read the payload from this file instead of generating it")
+		fs.StringVar(&rawUploadSize, "size", "1GB", "Size of the generated payload (ignored with --upload-file)")
+		fs.Int64Var(&cfg.Seed, "seed", 1, "Seed for the generated payload")
+	case modeMixed:
+		fs.StringVar(&cfg.Prefix, "prefix", defaultOpsPrefix, "Key prefix the workload creates and deletes its objects under")
+		fs.DurationVar(&cfg.Duration, "duration", 0, "Run operations until this time budget expires (e.g. 30s, 5m); 0 = run --ops operations")
+		fs.StringVar(&rawOpMix, "op-mix", "get=70,put=20,delete=10", "Relative weights of get, put, head, delete and list operations")
+		fs.StringVar(&rawObjectSize, "object-size", "4KB", "Size of objects written by PUT")
+		fs.IntVar(&cfg.Ops, "ops", 10000, "Operations per run (ignored with --duration)")
+		fs.IntVar(&cfg.SeedObjects, "objects", 100, "Objects created before the first run for GET/HEAD/DELETE to use")
+		fs.Int64Var(&cfg.Seed, "seed", 1, "Seed for the operation order and the contents of PUT objects")
 	}
-	if cfg.Key == "" {
-		return nil, fmt.Errorf("--key is required")
+	fs.StringVar(&rawConcurrency, "concurrency", "8", "Parallel workers — single value or comma-separated list for a sweep (e.g. 8 or 8,16,32)")
+	fs.IntVar(&cfg.Runs, "runs", 1, "Number of benchmark runs")
+	fs.IntVar(&cfg.WarmupRuns, "warmup-runs", 0, "Unmeasured runs before each concurrency level, to warm connections and caches")
+	fs.DurationVar(&cfg.WarmupDuration, "warmup-duration", 0, "Warm up for this long before each concurrency level instead of --warmup-runs (e.g. 10s)")
+	fs.StringVar(&cfg.Format, "format", formatText, "Results format: text, json, csv or tsv (csv and tsv have one row per run)")
+	fs.BoolVar(&jsonOutput, "json", false, "Emit results as JSON (same as --format json)")
+	fs.StringVar(&cfg.ChunksFile, "chunks-file", "", "Write one row per chunk request (index, range, size, start, TTFB, elapsed) to this file; TSV with --format tsv or a .tsv name, else CSV")
+	fs.StringVar(&cfg.TraceFile, "trace-file", "", "Write every chunk request with its worker, start, TTFB and elapsed time to this file")
+	fs.StringVar(&cfg.TraceFormat, "trace-format", traceJSONL, "Format of --trace-file: jsonl (one record per line) or chrome (trace event format for Perfetto or chrome://tracing)")
+	fs.StringVar(&cfg.MetricsListen, "metrics-listen", "", "Serve Prometheus/OpenMetrics metrics on this address while running (e.g. :9464); scrape /metrics")
+	fs.StringVar(&rawTags, "tags", "", "Tags for the results, comma-separated key=value pairs (e.g. env=nightly,cluster=ceph-a); sent with every --push-*")
+	fs.StringVar(&cfg.PushInflux, "push-influx", "", "Send the results as InfluxDB line protocol to this write URL (e.g. http://influx:8086/api/v2/write?org=o&bucket=b); token from $INFLUX_TOKEN")
+	fs.StringVar(&cfg.PushGateway, "push-gateway", "", "Send the results to this Prometheus Pushgateway (e.g. http://pushgateway:9091)")
+	fs.StringVar(&cfg.PushJob, "push-job", "s3bench", "Job name for --push-gateway")
+	fs.StringVar(&cfg.PushWebhook, "push-webhook", "", "POST the JSON results to this URL")
+	fs.DurationVar(&cfg.PushTimeout, "push-timeout", 30*time.Second, "Time limit for each --push-* request")
+	fs.StringVar(&cfg.Baseline, "baseline", "", "Compare the results with this earlier --json result and exit with status 2 if a --max-* threshold is crossed or a baseline cell is missing")
+	cfg.Thresholds.register(fs)
+	fs.StringVar(&rawPercentiles, "percentiles", defaultPercentiles, "Latency percentiles to report, comma-separated (e.g. 50,99,99.9,99.99)")
+	fs.StringVar(&cfg.HTMLReport, "html-report", "", "Write a self-contained HTML report with charts of throughput, latency and the timeline to this file (implies --timeline-interval 1s)")
+	fs.StringVar(&cfg.HistogramFile, "histogram-file", "", "Export the latency histograms of every concurrency level to this file, for \"s3bench histogram\" to merge")
+	fs.DurationVar(&cfg.TimelineInterval, "timeline-interval", 0, "Record throughput, finished and in-flight requests this often during each run (e.g. 500ms; 0 = off)")
+	fs.StringVar(&cfg.TimelineFile, "timeline-file", "", "Write the per-interval samples of every run to this CSV file (implies --timeline-interval 1s)")
+	fs.BoolVar(&cfg.AutoTune, "auto-tune", false, "Search for the concurrency knee, starting from --concurrency, instead of running fixed levels")
+	fs.Float64Var(&cfg.TuneMinGain, "tune-min-gain", 5, "Auto-tune: minimum throughput gain in percent for more workers to count as an improvement")
+	fs.DurationVar(&cfg.TuneMaxP99, "tune-max-p99", 0, "Auto-tune: reject levels whose P99 latency exceeds this (0 = no limit)")
+	fs.IntVar(&cfg.TuneMaxConc, "tune-max-concurrency", 1024, "Auto-tune: highest concurrency to try")
+	if err := fs.Parse(args); err != nil {
+		return nil, fs, err
+	}
+
+	if fs.NArg() > 0 {
+		return nil, fs, fmt.Errorf("unexpected argument %q", fs.Arg(0))
 	}
+	switch {
+	case jsonOutput && cfg.Format != formatText && cfg.Format != formatJSON:
+		return nil, fs, fmt.Errorf("--json conflicts with --format %s", cfg.Format)
+	case jsonOutput:
+		cfg.Format = formatJSON
+	case !slices.Contains([]string{formatText, formatJSON, formatCSV, formatTSV}, cfg.Format):
+		return nil, fs, fmt.Errorf("--format must be text, json, csv or tsv")
+	}
+	cfg.Quiet = cfg.Format != formatText
+	if cfg.TraceFormat != traceJSONL && cfg.TraceFormat != traceChrome {
+		return nil, fs, fmt.Errorf("--trace-format must be jsonl or chrome")
+	}
+	if err := cfg.Thresholds.validate(); err != nil {
+		return nil, fs, err
+	}
+	tags, err := parseTags(rawTags)
+	if err != nil {
+		return nil, fs, fmt.Errorf("invalid --tags: %w", err)
+	}
+	cfg.Tags = tags
+	if cfg.Thresholds.set() && cfg.Baseline == "" {
+		return nil, fs, fmt.Errorf("--max-* thresholds require --baseline")
+	}
+	if err := conn.parse(cfg); err != nil {
+		return nil, fs, err
+	}
+	switch mode {
+	case modeDownload:
+		sources := 0
+		for _, v := range []string{cfg.Key, cfg.Prefix, cfg.Manifest} {
+			if v != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fs, fmt.Errorf("exactly one of --key, --prefix or --manifest is required")
+		}
+		if cfg.OutputFile != "" && cfg.Key == "" {
+			return nil, fs, fmt.Errorf("--output can only be used with a single --key")
+		}
+		if cfg.DiscardOutput && cfg.OutputFile != "" {
+			return nil, fs, fmt.Errorf("--discard and --output are mutually exclusive")
+		}
+		if cfg.RandomRanges && cfg.Duration == 0 {
+			return nil, fs, fmt.Errorf("--random-ranges requires --duration")
+		}
+		if !cfg.DiscardOutput && cfg.OutputFile == "" {
+			// Default to discard if neither is specified
+			cfg.DiscardOutput = true
+		}
+	case modeUpload:
+		if cfg.Key == "" {
+			return nil, fs, fmt.Errorf("--key is required")
+		}
+		if cfg.UploadFile == "" {
+			size, err := parseByteSize(rawUploadSize)
+			if err != nil {
+				return nil, fs, fmt.Errorf("--size: %w", err)
+			}
+			cfg.UploadSize = size
+		}
+	case modeMixed:
+		if err := parseMixedConfig(cfg, rawOpMix, rawObjectSize); err != nil {
+			return nil, fs, err
+		}
+	}
+	if cfg.Duration < 0 {
+		return nil, fs, fmt.Errorf("--duration must be >= 0")
+	}
+
 	for _, part := range strings.Split(rawConcurrency, ",") {
 		part = strings.TrimSpace(part)
 		if part == "" {
@@ -61,34 +248,197 @@ func parseConfig() (*Config, error) {
 		}
 		n, err := strconv.Atoi(part)
 		if err != nil || n < 1 {
-			return nil, fmt.Errorf("--concurrency: invalid value %q (must be a positive integer)", part)
+			return nil, fs, fmt.Errorf("--concurrency: invalid value %q (must be a positive integer)", part)
 		}
 		cfg.ConcurrencyList = append(cfg.ConcurrencyList, n)
 	}
 	if len(cfg.ConcurrencyList) == 0 {
-		return nil, fmt.Errorf("--concurrency must have at least one value")
+		return nil, fs, fmt.Errorf("--concurrency must have at least one value")
 	}
 	if cfg.Runs < 1 {
-		return nil, fmt.Errorf("--runs must be >= 1")
+		return nil, fs, fmt.Errorf("--runs must be >= 1")
+	}
+	if cfg.WarmupRuns < 0 || cfg.WarmupDuration < 0 {
+		return nil, fs, fmt.Errorf("--warmup-runs and --warmup-duration must be >= 0")
+	}
+	if cfg.WarmupRuns > 0 && cfg.WarmupDuration > 0 {
+		return nil, fs, fmt.Errorf("--warmup-runs and --warmup-duration are mutually exclusive")
+	}
+	if cfg.WarmupDuration > 0 && mode == modeUpload {
+		return nil, fs, fmt.Errorf("--warmup-duration is not supported for uploads; use --warmup-runs")
+	}
+	percentiles, err := parsePercentiles(rawPercentiles)
+	if err != nil {
+		return nil, fs, err
 	}
-	if cfg.DiscardOutput && cfg.OutputFile != "" {
-		return nil, fmt.Errorf("--discard and --output are mutually exclusive")
+	cfg.Percentiles = percentiles
+	if (cfg.TimelineFile != "" || cfg.HTMLReport != "") && cfg.TimelineInterval == 0 {
+		cfg.TimelineInterval = time.Second
 	}
-	if !cfg.DiscardOutput && cfg.OutputFile == "" {
-		// Default to discard if neither is specified
-		cfg.DiscardOutput = true
+	if cfg.TimelineInterval < 0 || (cfg.TimelineInterval > 0 && cfg.TimelineInterval < 10*time.Millisecond) {
+		return nil, fs, fmt.Errorf("--timeline-interval must be 0 or at least 10ms")
 	}
 
+	if mode == modeMixed {
+		// The mixed workload has no chunks; a single placeholder cell keeps
+		// the chunk size × concurrency grid in main uniform.
+		cfg.ChunkSizeList = []int64{0}
+	}
+	for _, part := range strings.Split(rawChunkSize, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		size, err := parseByteSize(part)
+		if err != nil {
+			return nil, fs, fmt.Errorf("--chunk-size: %w", err)
+		}
+		if size < 1 {
+			return nil, fs, fmt.Errorf("--chunk-size must be > 0")
+		}
+		cfg.ChunkSizeList = append(cfg.ChunkSizeList, size)
+	}
+	if len(cfg.ChunkSizeList) == 0 {
+		return nil, fs, fmt.Errorf("--chunk-size must have at least one value")
+	}
+	cfg.ChunkSize = cfg.ChunkSizeList[0]
+
+	if cfg.AutoTune {
+		if len(cfg.ConcurrencyList) > 1 {
+			return nil, fs, fmt.Errorf("--auto-tune takes a single --concurrency value as its starting point")
+		}
+		if len(cfg.ChunkSizeList) > 1 {
+			return nil, fs, fmt.Errorf("--auto-tune takes a single --chunk-size value")
+		}
+		if cfg.TuneMinGain <= 0 {
+			return nil, fs, fmt.Errorf("--tune-min-gain must be > 0")
+		}
+		if cfg.TuneMaxP99 < 0 {
+			return nil, fs, fmt.Errorf("--tune-max-p99 must be >= 0")
+		}
+		if cfg.TuneMaxConc < cfg.ConcurrencyList[0] {
+			return nil, fs, fmt.Errorf("--tune-max-concurrency must be >= the starting --concurrency")
+		}
+	}
+	poolSizing := cfg.ConcurrencyList
+	if cfg.AutoTune {
+		// Size the idle pool for the largest level the tuner may try.
+		poolSizing = []int{cfg.TuneMaxConc}
+	}
+	resolveTransport(&cfg.Transport, poolSizing)
+
+	return cfg, fs, nil
+}
+
+// connectionFlags holds the raw values of shared flags that are parsed after
+// the command line has been read.
+type connectionFlags struct {
+	readBuffer, writeBuffer string
+	retryCodes, errorBudget string
+}
+
+// registerConnectionFlags registers the flags every command shares: where to
+// connect, credentials, and the HTTP transport, TLS and retry settings.
+// Call parse on the result once the flag set has been parsed.
+func registerConnectionFlags(fs *flag.FlagSet, cfg *Config) *connectionFlags {
+	cf := &connectionFlags{}
+	fs.StringVar(&cfg.Endpoint, "endpoint", "", "S3-compatible endpoint URL (empty = AWS)")
+	fs.StringVar(&cfg.Bucket, "bucket", "", "S3 bucket name (required)")
+	fs.StringVar(&cfg.Region, "region", "us-east-1", "AWS region")
+	fs.StringVar(&cfg.Profile, "profile", "impossible", "AWS named profile from ~/.aws/credentials or ~/.aws/config")
+	fs.StringVar(&cfg.AccessKeyID, "access-key-id", "", "AWS access key ID (overrides profile)")
+	fs.StringVar(&cfg.SecretAccessKey, "secret-access-key", "", "AWS secret access key (overrides profile)")
+	fs.IntVar(&cfg.Transport.MaxConnsPerHost, "max-conns-per-host", 0, "Maximum connections per host, including in-use ones (0 = unlimited)")
+	fs.IntVar(&cfg.Transport.MaxIdleConns, "max-idle-conns", 0, "Idle connection pool size across all hosts (0 = automatic)")
+	fs.IntVar(&cfg.Transport.MaxIdleConnsPerHost, "max-idle-conns-per-host", 0, "Idle connections kept per host (0 = highest --concurrency value)")
+	fs.DurationVar((*time.Duration)(&cfg.Transport.IdleConnTimeout), "idle-conn-timeout", 90*time.Second, "How long an idle connection is kept open")
+	fs.DurationVar((*time.Duration)(&cfg.Transport.DialTimeout), "dial-timeout", 30*time.Second, "TCP connect timeout")
+	fs.DurationVar((*time.Duration)(&cfg.Transport.ResponseHeaderTimeout), "response-header-timeout", 0, "Maximum wait for response headers after sending a request (0 = no limit)")
+	fs.BoolVar(&cfg.Transport.DisableKeepAlives, "disable-keepalive", false, "Open a new connection for every request")
+	fs.StringVar(&cfg.Transport.HTTP2, "http2", http2Auto, "HTTP/2 mode: auto (negotiate over TLS), force (fail without HTTP/2) or off")
+	fs.StringVar(&cf.readBuffer, "read-buffer-size", "", "Transport read buffer size per connection (e.g. 64KB; empty = Go default)")
+	fs.StringVar(&cf.writeBuffer, "write-buffer-size", "", "Transport write buffer size per connection (e.g. 64KB; empty = Go default)")
+	fs.StringVar(&cfg.Transport.CABundle, "ca-bundle", "", "PEM file of extra CA certificates to trust (e.g. an internal CA)")
+	fs.StringVar(&cfg.Transport.ClientCert, "client-cert", "", "PEM client certificate for mutual TLS (requires --client-key)")
+	fs.StringVar(&cfg.Transport.ClientKey, "client-key", "", "PEM private key for --client-cert")
+	fs.StringVar(&cfg.Transport.TLSMinVersion, "tls-min-version", "1.2", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	fs.BoolVar(&cfg.Transport.InsecureSkipVerify, "insecure-skip-verify", false, "Disable TLS certificate verification (INSECURE; testing only)")
+	fs.IntVar(&cfg.Retry.MaxAttempts, "retries", 3, "Maximum attempts per chunk, including the first (1 = no retries)")
+	fs.DurationVar((*time.Duration)(&cfg.Retry.Backoff), "retry-backoff", 100*time.Millisecond, "Delay before the first retry; doubled for each further retry, with jitter")
+	fs.DurationVar((*time.Duration)(&cfg.Retry.MaxBackoff), "retry-max-backoff", 5*time.Second, "Upper limit on the delay between retries")
+	fs.StringVar(&cf.retryCodes, "retry-status-codes", "429,500,502,503,504", "HTTP status codes that are retried (network errors always are)")
+	fs.StringVar(&cf.errorBudget, "error-budget", "0", "Chunks allowed to fail after retries before a run is aborted: a count (e.g. 5) or a percentage (e.g. 1%)")
+	return cf
+}
+
+// parse validates the shared flags and fills in the values that need parsing.
+// The transport pool is sized separately by resolveTransport, since that
+// depends on each command's concurrency.
+func (cf *connectionFlags) parse(cfg *Config) error {
+	if cfg.Bucket == "" {
+		return fmt.Errorf("--bucket is required")
+	}
+	if err := validateTransport(&cfg.Transport, cfg.Endpoint); err != nil {
+		return err
+	}
+	for _, buf := range []struct {
+		flag string
+		raw  string
+		dst  *int
+	}{
+		{"--read-buffer-size", cf.readBuffer, &cfg.Transport.ReadBufferSize},
+		{"--write-buffer-size", cf.writeBuffer, &cfg.Transport.WriteBufferSize},
+	} {
+		if buf.raw == "" {
+			continue
+		}
+		n, err := parseByteSize(buf.raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", buf.flag, err)
+		}
+		*buf.dst = int(n)
+	}
+	if cfg.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("--retries must be >= 1")
+	}
+	if cfg.Retry.Backoff < 0 || cfg.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("retry backoff must be >= 0")
+	}
 	var err error
-	cfg.ChunkSize, err = parseByteSize(rawChunkSize)
+	cfg.Retry.StatusCodes, err = parseStatusCodes(cf.retryCodes)
+	if err != nil {
+		return fmt.Errorf("--retry-status-codes: %w", err)
+	}
+	cfg.ErrorBudget, err = parseErrorBudget(cf.errorBudget)
 	if err != nil {
-		return nil, fmt.Errorf("--chunk-size: %w", err)
+		return fmt.Errorf("--error-budget: %w", err)
+	}
+	return nil
+}
+
+// parseMixedConfig validates the flags used by the mixed small-object workload.
+// --prefix names the key space the workload creates and deletes objects in.
+func parseMixedConfig(cfg *Config, rawOpMix, rawObjectSize string) error {
+	if cfg.Prefix == "" {
+		return fmt.Errorf("--prefix must not be empty")
+	}
+	if cfg.Duration == 0 && cfg.Ops < 1 {
+		return fmt.Errorf("--ops must be >= 1")
 	}
-	if cfg.ChunkSize < 1 {
-		return nil, fmt.Errorf("--chunk-size must be > 0")
+	if cfg.SeedObjects < 0 {
+		return fmt.Errorf("--objects must be >= 0")
 	}
 
-	return cfg, nil
+	var err error
+	cfg.OpMix, err = parseOpMix(rawOpMix)
+	if err != nil {
+		return fmt.Errorf("--op-mix: %w", err)
+	}
+	cfg.ObjectSize, err = parseByteSize(rawObjectSize)
+	if err != nil {
+		return fmt.Errorf("--object-size: %w", err)
+	}
+	return nil
 }
 
 // namedSizes maps single-word preset names to their byte values.
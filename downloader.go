@@ -21,19 +21,57 @@ import (
 type countingReader struct {
 	r       io.Reader
 	counter *atomic.Int64
+	n       int64
 }
 
 func (cr *countingReader) Read(p []byte) (int, error) {
 	n, err := cr.r.Read(p)
 	if n > 0 {
 		cr.counter.Add(int64(n))
+		cr.n += int64(n)
 	}
 	return n, err
 }
 
+// rollback removes the bytes counted so far, so a chunk that fails part-way
+// and is retried is not counted twice.
+func (cr *countingReader) rollback() {
+	cr.counter.Add(-cr.n)
+	cr.n = 0
+}
+
+// timedWriter wraps an io.Writer and accumulates the time spent inside Write.
+// It lets a chunk separate time spent writing to disk from time spent
+// waiting on the network.
+type timedWriter struct {
+	w       io.Writer
+	elapsed time.Duration
+}
+
+func (tw *timedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := tw.w.Write(p)
+	tw.elapsed += time.Since(start)
+	return n, err
+}
+
+// copyBufferSize is the per-worker buffer used to stream a chunk body to disk.
+// Memory in --output mode is bounded by concurrency × copyBufferSize regardless
+// of object or chunk size.
+const copyBufferSize = 1 << 20
+
+var copyBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
 // ChunkSpec describes a single byte-range segment of the object.
 type ChunkSpec struct {
 	Index      int
+	Key        string // object the range belongs to; empty means cfg.Key
+	Op         string // mixed workload only: operation to perform
 	RangeStart int64
 	RangeEnd   int64 // inclusive, per RFC 7233
 	Size       int64 // RangeEnd - RangeStart + 1
@@ -41,12 +79,22 @@ type ChunkSpec struct {
 
 // ChunkResult holds the timing and outcome of one chunk download.
 type ChunkResult struct {
-	Index        int
-	Size         int64
-	StartTime    time.Time
-	TTFB         time.Duration // time from GetObject call to response headers received
-	ElapsedTotal time.Duration // full elapsed time including body drain
-	Err          error
+	Index          int
+	Worker         int // worker goroutine of the pool that ran the chunk, from 0
+	Key            string
+	Op             string // mixed workload only: operation performed
+	Fallback       bool   // mixed workload only: a planned GET, HEAD or DELETE run as a PUT
+	Size           int64
+	StartTime      time.Time
+	TTFB           time.Duration // time from GetObject call to response headers received
+	ElapsedTotal   time.Duration // full elapsed time including body drain
+	WriteTime      time.Duration // portion of ElapsedTotal spent writing to the output file
+	Phases         PhaseTiming   // HTTP connection phases of the request
+	Verified       bool          // --verify: the chunk passed its per-range check
+	CRC            uint64        // --verify: CRC of the chunk, for objects checked by CRC
+	Attempts       int           // requests made for this chunk, including retries
+	FailedAttempts []string      // error label (status code, "network" or "other") of each failed attempt
+	Err            error
 }
 
 // DownloadResult aggregates all chunk results for a single run.
@@ -54,6 +102,7 @@ type DownloadResult struct {
 	Chunks    []ChunkResult
 	TotalTime time.Duration
 	TTFB      time.Duration // TTFB of the first chunk to respond
+	SyncTime  time.Duration // time to fsync the output file after the run, if written
 }
 
 // getObjectSize performs a HeadObject to determine the content length of the target object.
@@ -93,75 +142,25 @@ func planChunks(objectSize, chunkSize int64) []ChunkSpec {
 }
 
 // downloadObject downloads all chunks concurrently using a fixed-size worker pool.
-// outBufs must be len(chunks) if writing is enabled, or nil for discard mode.
-// progress, if non-nil, is incremented as bytes are received (for live display).
+// out receives each chunk at its own offset if writing is enabled, or is nil for
+// discard mode. The destination should be pre-sized to the object size.
+// live is updated as bytes are received and chunks finish (for the progress
+// line and the timeline).
+// checks, if non-nil, verifies each chunk's data as it streams past (--verify).
 func downloadObject(
 	ctx context.Context,
 	client *s3.Client,
 	cfg *Config,
 	chunks []ChunkSpec,
-	outBufs [][]byte,
-	progress *atomic.Int64,
+	out io.WriterAt,
+	live *liveCounters,
+	checks verifyPlan,
 	concurrency int,
 ) (DownloadResult, error) {
 
-	jobs := make(chan ChunkSpec, len(chunks))
-	for _, c := range chunks {
-		jobs <- c
-	}
-	close(jobs)
-
-	results := make([]ChunkResult, len(chunks))
-
-	var (
-		wg        sync.WaitGroup
-		mu        sync.Mutex
-		firstTTFB time.Duration
-		ttfbSet   bool
-	)
-
-	overallStart := time.Now()
-
-	workers := concurrency
-	if workers > len(chunks) {
-		workers = len(chunks)
-	}
-
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for chunk := range jobs {
-				res := downloadChunk(ctx, client, cfg, chunk, outBufs, progress)
-				// Index-keyed write — no lock needed; each goroutine owns a unique index.
-				results[chunk.Index] = res
-
-				if res.Err == nil {
-					mu.Lock()
-					if !ttfbSet {
-						firstTTFB = res.TTFB
-						ttfbSet = true
-					}
-					mu.Unlock()
-				}
-			}
-		}()
-	}
-
-	wg.Wait()
-	totalTime := time.Since(overallStart)
-
-	for _, r := range results {
-		if r.Err != nil {
-			return DownloadResult{}, r.Err
-		}
-	}
-
-	return DownloadResult{
-		Chunks:    results,
-		TotalTime: totalTime,
-		TTFB:      firstTTFB,
-	}, nil
+	return runWorkerPool(cfg, chunks, concurrency, live, func(chunk ChunkSpec) ChunkResult {
+		return downloadChunk(ctx, client, cfg, chunk, out, &live.Bytes, checks)
+	})
 }
 
 // downloadChunk performs a single byte-range GetObject request and records timing.
@@ -170,23 +169,31 @@ func downloadChunk(
 	client *s3.Client,
 	cfg *Config,
 	chunk ChunkSpec,
-	outBufs [][]byte,
+	out io.WriterAt,
 	progress *atomic.Int64,
+	checks verifyPlan,
 ) ChunkResult {
 
+	key := chunk.Key
+	if key == "" {
+		key = cfg.Key
+	}
+
 	rangeHeader := fmt.Sprintf("bytes=%d-%d", chunk.RangeStart, chunk.RangeEnd)
 	start := time.Now()
+	ctx, rec := withPhaseTrace(ctx, start)
 
 	resp, err := client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(cfg.Bucket),
-		Key:    aws.String(cfg.Key),
+		Key:    aws.String(key),
 		Range:  aws.String(rangeHeader),
-	})
+	}, noSDKRetries)
 	if err != nil {
 		return ChunkResult{
 			Index:     chunk.Index,
+			Key:       key,
 			StartTime: start,
-			Err:       fmt.Errorf("GetObject chunk %d (range %s): %w", chunk.Index, rangeHeader, err),
+			Err:       fmt.Errorf("GetObject %s chunk %d (range %s): %w", key, chunk.Index, rangeHeader, err),
 		}
 	}
 	defer resp.Body.Close()
@@ -194,48 +201,91 @@ func downloadChunk(
 	// TTFB: time elapsed from request dispatch to response headers received.
 	// The SDK returns after receiving headers; body bytes are not yet consumed.
 	ttfb := time.Since(start)
+	phases := rec.timings()
 
 	// Wrap the body so bytes are counted as they flow through, giving
 	// live progress even within a single large chunk.
 	var body io.Reader = resp.Body
+	var counter *countingReader
 	if progress != nil {
-		body = &countingReader{r: resp.Body, counter: progress}
+		counter = &countingReader{r: resp.Body, counter: progress}
+		body = counter
+	}
+	var checker *chunkChecker
+	if checks != nil {
+		if checker = newChunkChecker(checks[key], chunk); checker != nil {
+			body = io.TeeReader(body, checker)
+		}
 	}
 
 	var n int64
-	if outBufs != nil {
-		// Write mode: allocate exactly chunk.Size bytes and fill from body.
-		buf := make([]byte, chunk.Size)
-		var readN int
-		readN, err = io.ReadFull(body, buf)
-		n = int64(readN)
-		if err != nil && err != io.ErrUnexpectedEOF {
+	var writeTime time.Duration
+	if out != nil {
+		// Write mode: stream the body straight to the chunk's offset in the
+		// output file through a small pooled buffer.
+		tw := &timedWriter{w: io.NewOffsetWriter(out, chunk.RangeStart)}
+		bufp := copyBufPool.Get().(*[]byte)
+		n, err = io.CopyBuffer(tw, body, *bufp)
+		copyBufPool.Put(bufp)
+		writeTime = tw.elapsed
+		if err != nil {
+			if counter != nil {
+				counter.rollback()
+			}
 			return ChunkResult{
 				Index:     chunk.Index,
+				Key:       key,
 				StartTime: start,
 				TTFB:      ttfb,
-				Err:       fmt.Errorf("reading chunk %d body: %w", chunk.Index, err),
+				Phases:    phases,
+				Err:       fmt.Errorf("writing chunk %d to output: %w", chunk.Index, err),
 			}
 		}
-		outBufs[chunk.Index] = buf[:n]
 	} else {
 		// Discard mode: drain body without allocating an output buffer.
 		n, err = io.Copy(io.Discard, body)
 		if err != nil {
+			if counter != nil {
+				counter.rollback()
+			}
 			return ChunkResult{
 				Index:     chunk.Index,
+				Key:       key,
 				StartTime: start,
 				TTFB:      ttfb,
+				Phases:    phases,
 				Err:       fmt.Errorf("draining chunk %d body: %w", chunk.Index, err),
 			}
 		}
 	}
 
+	var crc uint64
+	if checker != nil {
+		if crc, err = checker.finish(key, chunk, n); err != nil {
+			if counter != nil {
+				counter.rollback()
+			}
+			return ChunkResult{
+				Index:     chunk.Index,
+				Key:       key,
+				StartTime: start,
+				TTFB:      ttfb,
+				Phases:    phases,
+				Err:       err,
+			}
+		}
+	}
+
 	return ChunkResult{
 		Index:        chunk.Index,
+		Key:          key,
 		Size:         n,
+		Verified:     checker != nil,
+		CRC:          crc,
 		StartTime:    start,
 		TTFB:         ttfb,
 		ElapsedTotal: time.Since(start),
+		WriteTime:    writeTime,
+		Phases:       phases,
 	}
 }
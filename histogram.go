@@ -0,0 +1,436 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// histSubBits sets the resolution of a LatencyHistogram: every power-of-two
+// range of values is split into 1<<(histSubBits-1) buckets, so a recorded
+// value is off by at most 1 part in 1024 — three significant digits.
+const histSubBits = 11
+
+const (
+	histSubCount = 1 << histSubBits // values below this get a bucket each
+	histSubHalf  = histSubCount / 2 // buckets per power of two above that
+)
+
+// LatencyHistogram records durations in log-linear buckets, in the manner of
+// an HDR histogram. Memory depends on the spread of the values rather than
+// their number, percentiles to any depth can be read from it, and two
+// histograms merge by adding their buckets, so runs, workers and separate
+// machines can be combined. Min, max and the mean are exact.
+type LatencyHistogram struct {
+	counts map[int32]uint64
+	total  uint64
+	sum    int64
+	min    time.Duration
+	max    time.Duration
+}
+
+// newLatencyHistogram returns an empty histogram.
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{counts: map[int32]uint64{}}
+}
+
+// histIndex returns the bucket of a value in nanoseconds.
+func histIndex(v int64) int32 {
+	if v < histSubCount {
+		return int32(max(v, 0))
+	}
+	shift := bits.Len64(uint64(v)) - histSubBits
+	sub := v >> shift
+	return int32(histSubCount + (shift-1)*histSubHalf + int(sub-histSubHalf))
+}
+
+// histUpper returns the highest value that falls in bucket idx.
+func histUpper(idx int32) int64 {
+	if idx < histSubCount {
+		return int64(idx)
+	}
+	k := int(idx) - histSubCount
+	shift := k/histSubHalf + 1
+	sub := int64(k%histSubHalf + histSubHalf)
+	return sub<<shift + 1<<shift - 1
+}
+
+// Record adds one duration.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if h.total == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.counts[histIndex(int64(d))]++
+	h.total++
+	h.sum += int64(d)
+}
+
+// Merge adds every value recorded in o.
+func (h *LatencyHistogram) Merge(o *LatencyHistogram) {
+	if o == nil || o.total == 0 {
+		return
+	}
+	if h.total == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+	for idx, n := range o.counts {
+		h.counts[idx] += n
+	}
+	h.total += o.total
+	h.sum += o.sum
+}
+
+// Count returns the number of values recorded.
+func (h *LatencyHistogram) Count() uint64 { return h.total }
+
+// Stats returns min, max, mean and P50/P95/P99, plus the requested
+// percentiles in order. A percentile is reported as the highest value of the
+// bucket holding it, capped at the maximum.
+func (h *LatencyHistogram) Stats(percentiles []float64) LatencyStats {
+	if h.total == 0 {
+		return LatencyStats{}
+	}
+	idxs := make([]int32, 0, len(h.counts))
+	for idx := range h.counts {
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+
+	at := func(p float64) time.Duration {
+		rank := uint64(math.Ceil(p / 100 * float64(h.total)))
+		rank = max(rank, 1)
+		var seen uint64
+		for _, idx := range idxs {
+			seen += h.counts[idx]
+			if seen >= rank {
+				return min(max(time.Duration(histUpper(idx)), h.min), h.max)
+			}
+		}
+		return h.max
+	}
+
+	stats := LatencyStats{
+		Min:  millis(h.min),
+		Max:  millis(h.max),
+		Mean: millis(h.sum / int64(h.total)),
+		P50:  millis(at(50)),
+		P95:  millis(at(95)),
+		P99:  millis(at(99)),
+	}
+	for _, p := range percentiles {
+		stats.Percentiles = append(stats.Percentiles, Percentile{Percentile: p, Value: millis(at(p))})
+	}
+	return stats
+}
+
+// latencyBin is a range of latencies and the number of values in it.
+type latencyBin struct {
+	Lo, Hi time.Duration
+	Count  uint64
+}
+
+// logBins regroups the histogram into n bins of equal width on a log scale,
+// from its minimum to its maximum, for plotting.
+func (h *LatencyHistogram) logBins(n int) []latencyBin {
+	if h.total == 0 {
+		return nil
+	}
+	lo := float64(max(h.min, 1))
+	hi := float64(max(h.max, h.min+1))
+	span := math.Log(hi / lo)
+	if span == 0 {
+		// Every value is within a nanosecond of zero; one bin holds them all.
+		return []latencyBin{{Lo: h.min, Hi: h.max, Count: h.total}}
+	}
+	edge := func(i int) time.Duration {
+		return time.Duration(lo * math.Exp(span*float64(i)/float64(n)))
+	}
+	bins := make([]latencyBin, n)
+	for i := range bins {
+		bins[i].Lo, bins[i].Hi = edge(i), edge(i+1)
+	}
+	for idx, count := range h.counts {
+		v := float64(min(max(histUpper(idx), int64(h.min)), int64(h.max)))
+		i := int(math.Log(max(v, lo)/lo) / span * float64(n))
+		bins[min(i, n-1)].Count += count
+	}
+	return bins
+}
+
+// histogramJSON is the exported form of a LatencyHistogram: its non-empty
+// buckets as [index, count] pairs, in index order.
+type histogramJSON struct {
+	SubBucketBits int        `json:"sub_bucket_bits"`
+	Count         uint64     `json:"count"`
+	Min           int64      `json:"min_ns"`
+	Max           int64      `json:"max_ns"`
+	Sum           int64      `json:"sum_ns"`
+	Buckets       [][2]int64 `json:"buckets"`
+}
+
+// MarshalJSON exports the histogram so it can be merged with others later.
+func (h *LatencyHistogram) MarshalJSON() ([]byte, error) {
+	out := histogramJSON{SubBucketBits: histSubBits, Count: h.total, Min: int64(h.min), Max: int64(h.max), Sum: h.sum}
+	out.Buckets = make([][2]int64, 0, len(h.counts))
+	for idx, n := range h.counts {
+		out.Buckets = append(out.Buckets, [2]int64{int64(idx), int64(n)})
+	}
+	slices.SortFunc(out.Buckets, func(a, b [2]int64) int { return int(a[0] - b[0]) })
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads a histogram written by MarshalJSON.
+func (h *LatencyHistogram) UnmarshalJSON(data []byte) error {
+	var in histogramJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.SubBucketBits != histSubBits {
+		return fmt.Errorf("histogram has %d sub-bucket bits; this version of s3bench reads %d", in.SubBucketBits, histSubBits)
+	}
+	*h = LatencyHistogram{counts: make(map[int32]uint64, len(in.Buckets)), min: time.Duration(in.Min), max: time.Duration(in.Max), sum: in.Sum}
+	for _, b := range in.Buckets {
+		if b[0] < 0 || b[0] > math.MaxInt32 || b[1] < 0 {
+			return fmt.Errorf("histogram bucket %v is out of range", b)
+		}
+		h.counts[int32(b[0])] += uint64(b[1])
+		h.total += uint64(b[1])
+	}
+	if h.total != in.Count {
+		return fmt.Errorf("histogram buckets hold %d values but its count is %d", h.total, in.Count)
+	}
+	return nil
+}
+
+// Percentile is one requested latency percentile.
+type Percentile struct {
+	Percentile float64 `json:"percentile"`
+	Value      millis  `json:"value_ms"`
+}
+
+// percentileLabel formats a percentile for column headings, e.g. "P99.9".
+func percentileLabel(p float64) string {
+	return "P" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// parsePercentiles parses a comma-separated --percentiles list such as
+// "50,99,99.9,99.99".
+func parsePercentiles(s string) ([]float64, error) {
+	var out []float64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(part), "p"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q: want a number above 0 and at most 100", part)
+		}
+		out = append(out, p)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("--percentiles must have at least one value")
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// HistogramExport is the file written by --histogram-file: the latency
+// histogram of every grid cell and operation, with the measured runs merged.
+type HistogramExport struct {
+	Histograms []HistogramRecord `json:"histograms"`
+}
+
+// HistogramRecord is the merged latency histogram of one operation in one
+// grid cell.
+type HistogramRecord struct {
+	Operation   string            `json:"operation"`
+	ChunkSize   int64             `json:"chunk_size_bytes"`
+	Concurrency int               `json:"concurrency"`
+	Runs        int               `json:"runs"`
+	Latency     *LatencyHistogram `json:"latency"`
+}
+
+// histogramRecords merges the measured runs of each sweep by operation.
+// Operations are listed in validOps order, after the single operation of a
+// download or upload.
+func histogramRecords(sweeps []ConcurrencySweep) []HistogramRecord {
+	var records []HistogramRecord
+	for _, sw := range sweeps {
+		merged := mergeHistograms(sw.Summaries)
+		for _, op := range append([]string{modeDownload, modeUpload}, validOps...) {
+			if h := merged[op]; h != nil {
+				records = append(records, HistogramRecord{
+					Operation:   op,
+					ChunkSize:   sw.ChunkSize,
+					Concurrency: sw.Concurrency,
+					Runs:        len(sw.Summaries),
+					Latency:     h,
+				})
+			}
+		}
+	}
+	return records
+}
+
+// writeHistogramFile writes records to path as a HistogramExport.
+func writeHistogramFile(path string, records []HistogramRecord) error {
+	data, err := json.Marshal(HistogramExport{Histograms: records})
+	if err != nil {
+		return fmt.Errorf("encoding histograms: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing histogram file: %w", err)
+	}
+	return nil
+}
+
+// readHistogramFile reads a file written by writeHistogramFile.
+func readHistogramFile(path string) ([]HistogramRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading histograms: %w", err)
+	}
+	var export HistogramExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("%s: not an s3bench histogram file: %w", path, err)
+	}
+	for _, r := range export.Histograms {
+		if r.Latency == nil {
+			return nil, fmt.Errorf("%s: %s record has no histogram", path, r.Operation)
+		}
+	}
+	return export.Histograms, nil
+}
+
+// mergeRecords merges the records for the same operation, chunk size and
+// concurrency, keeping the order in which each was first seen.
+func mergeRecords(records []HistogramRecord) []HistogramRecord {
+	type key struct {
+		op          string
+		chunkSize   int64
+		concurrency int
+	}
+	var merged []HistogramRecord
+	index := map[key]int{}
+	for _, r := range records {
+		k := key{r.Operation, r.ChunkSize, r.Concurrency}
+		i, ok := index[k]
+		if !ok {
+			i = len(merged)
+			index[k] = i
+			merged = append(merged, HistogramRecord{
+				Operation:   r.Operation,
+				ChunkSize:   r.ChunkSize,
+				Concurrency: r.Concurrency,
+				Latency:     newLatencyHistogram(),
+			})
+		}
+		merged[i].Runs += r.Runs
+		merged[i].Latency.Merge(r.Latency)
+	}
+	return merged
+}
+
+// HistogramSummary is the --json output of "s3bench histogram".
+type HistogramSummary struct {
+	Operation   string       `json:"operation"`
+	ChunkSize   int64        `json:"chunk_size_bytes"`
+	Concurrency int          `json:"concurrency"`
+	Runs        int          `json:"runs"`
+	Count       uint64       `json:"count"`
+	Latency     LatencyStats `json:"latency"`
+}
+
+// runHistogram implements "s3bench histogram": it merges the --histogram-file
+// exports of several runs, for example from clients benchmarking the same
+// endpoint together, and reports percentiles over all of them.
+func runHistogram(args []string) {
+	fs := newFlagSet("histogram")
+	rawPercentiles := fs.String("percentiles", defaultPercentiles, "Latency percentiles to report, comma-separated")
+	jsonOutput := fs.Bool("json", false, "Emit the merged percentiles as JSON")
+	output := fs.String("output", "", "Also write the merged histograms to this file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: s3bench histogram [flags] FILE...\n\nMerge latency histograms exported with --histogram-file and report their percentiles.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		usageError(fs, err)
+	}
+	if fs.NArg() == 0 {
+		usageError(fs, fmt.Errorf("histogram takes at least one histogram file"))
+	}
+	percentiles, err := parsePercentiles(*rawPercentiles)
+	if err != nil {
+		usageError(fs, err)
+	}
+
+	var records []HistogramRecord
+	for _, path := range fs.Args() {
+		r, err := readHistogramFile(path)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		records = append(records, r...)
+	}
+	merged := mergeRecords(records)
+	if *output != "" {
+		if err := writeHistogramFile(*output, merged); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	summaries := make([]HistogramSummary, 0, len(merged))
+	for _, r := range merged {
+		summaries = append(summaries, HistogramSummary{
+			Operation:   r.Operation,
+			ChunkSize:   r.ChunkSize,
+			Concurrency: r.Concurrency,
+			Runs:        r.Runs,
+			Count:       r.Latency.Count(),
+			Latency:     r.Latency.Stats(percentiles),
+		})
+	}
+	if *jsonOutput {
+		printJSON(summaries)
+		return
+	}
+	printHistogramSummaries(summaries, percentiles, fs.NArg())
+}
+
+// printHistogramSummaries prints one row of merged latencies per operation
+// and grid cell.
+func printHistogramSummaries(summaries []HistogramSummary, percentiles []float64, files int) {
+	fmt.Printf("Latency merged from %s\n\n", plural(files, "file"))
+	fmt.Printf("  %-8s  %-10s  %7s  %10s  %10s", "Op", "Chunk size", "Workers", "Count", "Mean")
+	for _, p := range percentiles {
+		fmt.Printf("  %10s", percentileLabel(p))
+	}
+	fmt.Printf("  %10s\n", "Max")
+	for _, s := range summaries {
+		chunk := "—"
+		if s.ChunkSize > 0 {
+			chunk = formatBytes(s.ChunkSize)
+		}
+		fmt.Printf("  %-8s  %-10s  %7d  %10d  %10s", s.Operation, chunk, s.Concurrency, s.Count, formatDuration(time.Duration(s.Latency.Mean)))
+		for _, p := range s.Latency.Percentiles {
+			fmt.Printf("  %10s", formatDuration(time.Duration(p.Value)))
+		}
+		fmt.Printf("  %10s\n", formatDuration(time.Duration(s.Latency.Max)))
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportPalette colours the series of the HTML report's charts.
+var reportPalette = []string{"#2563eb", "#dc2626", "#16a34a", "#d97706", "#7c3aed", "#0891b2", "#db2777", "#4b5563"}
+
+// Chart geometry of the HTML report, in SVG user units.
+const (
+	chartWidth  = 760
+	chartHeight = 300
+	chartLeft   = 64
+	chartRight  = 16
+	chartTop    = 16
+	chartBottom = 56
+)
+
+// histogramBins is the number of bars in a chunk latency histogram.
+const histogramBins = 40
+
+// reportData is what the HTML report template renders.
+type reportData struct {
+	Title      string
+	Generated  string
+	Config     [][2]string
+	Columns    []string
+	Rows       [][]string
+	Throughput template.HTML
+	Latency    template.HTML
+	Timeline   template.HTML // empty when no run has timeline samples
+	Histograms []reportHistogram
+}
+
+// reportHistogram is the chunk latency histogram of one grid cell.
+type reportHistogram struct {
+	Cell  string
+	Chart template.HTML
+}
+
+// writeHTMLReport writes a single HTML file with inline SVG charts of the
+// sweeps: throughput and latency percentiles per grid cell, the timeline of
+// every measured run, a chunk latency histogram per cell, a table of the
+// results and the settings. It needs no external assets.
+func writeHTMLReport(path string, cfg *Config, sweeps []ConcurrencySweep) error {
+	if len(sweeps) == 0 {
+		return nil
+	}
+	unit, metric := comparisonMetric(sweeps)
+
+	multiChunk := false
+	for _, sw := range sweeps {
+		multiChunk = multiChunk || (cfg.Mode != modeMixed && sw.ChunkSize != sweeps[0].ChunkSize)
+	}
+	labels := make([]string, len(sweeps))
+	for i, sw := range sweeps {
+		labels[i] = fmt.Sprintf("%d workers", sw.Concurrency)
+		if multiChunk {
+			labels[i] = fmt.Sprintf("%s × %d", formatBytes(sw.ChunkSize), sw.Concurrency)
+		}
+	}
+
+	// Latency comes from the histograms of all measured runs of a cell, every
+	// operation of a mixed run together.
+	hists := make([]*LatencyHistogram, len(sweeps))
+	stats := make([]LatencyStats, len(sweeps))
+	for i, sw := range sweeps {
+		hists[i] = newLatencyHistogram()
+		for _, h := range mergeHistograms(sw.Summaries) {
+			hists[i].Merge(h)
+		}
+		stats[i] = hists[i].Stats(cfg.Percentiles)
+	}
+
+	data := reportData{
+		Title:     fmt.Sprintf("s3bench %s report", cfg.Mode),
+		Generated: time.Now().Format("2006-01-02 15:04:05 MST"),
+		Config:    reportConfig(cfg, sweeps),
+	}
+
+	// Results table.
+	if cfg.Mode != modeMixed {
+		data.Columns = append(data.Columns, "Chunk size")
+	}
+	data.Columns = append(data.Columns, "Workers", "Runs", "Min "+unit, "Mean "+unit, "Max "+unit)
+	for _, p := range cfg.Percentiles {
+		data.Columns = append(data.Columns, percentileLabel(p))
+	}
+	data.Columns = append(data.Columns, "Retries", "Failed chunks")
+	for i, sw := range sweeps {
+		var row []string
+		if cfg.Mode != modeMixed {
+			row = append(row, formatBytes(sw.ChunkSize))
+		}
+		minV, meanV, maxV := metric(sw.Aggregate)
+		row = append(row, strconv.Itoa(sw.Concurrency), strconv.Itoa(sw.Aggregate.Runs),
+			fmt.Sprintf("%.1f", minV), fmt.Sprintf("%.1f", meanV), fmt.Sprintf("%.1f", maxV))
+		// A cell without a successful chunk has no percentiles; keep its
+		// columns aligned with a dash.
+		for j := range cfg.Percentiles {
+			if j < len(stats[i].Percentiles) {
+				row = append(row, formatDuration(time.Duration(stats[i].Percentiles[j].Value)))
+			} else {
+				row = append(row, "—")
+			}
+		}
+		var retries, failed int
+		for _, s := range sw.Summaries {
+			retries += s.Errors.Retries
+			failed += s.Errors.Failed
+		}
+		data.Rows = append(data.Rows, append(row, strconv.Itoa(retries), strconv.Itoa(failed)))
+	}
+
+	// Throughput per cell, with the range of the runs as whiskers.
+	bars := make([]chartBar, len(sweeps))
+	for i, sw := range sweeps {
+		minV, meanV, maxV := metric(sw.Aggregate)
+		bars[i] = chartBar{label: labels[i], value: meanV, lo: minV, hi: maxV}
+	}
+	data.Throughput = barChart(bars, "Mean "+unit)
+
+	// Latency percentiles per cell, skipping cells that have none.
+	var latency []chartSeries
+	for j, p := range cfg.Percentiles {
+		s := chartSeries{name: percentileLabel(p)}
+		for i := range sweeps {
+			if j >= len(stats[i].Percentiles) {
+				continue
+			}
+			s.points = append(s.points, [2]float64{float64(i) + 0.5, durationMillis(time.Duration(stats[i].Percentiles[j].Value))})
+		}
+		latency = append(latency, s)
+	}
+	data.Latency = lineChart(latency, labels, "", "Chunk latency (ms)")
+
+	// Timeline of every measured run.
+	var timeline []chartSeries
+	for i, sw := range sweeps {
+		for _, s := range sw.Summaries {
+			if len(s.Timeline) == 0 {
+				continue
+			}
+			series := chartSeries{name: fmt.Sprintf("%s, run %d", labels[i], s.RunNumber)}
+			for _, t := range s.Timeline {
+				rate := t.ThroughputMB
+				if unit == "ops/s" {
+					rate = t.RequestsPerSec
+				}
+				series.points = append(series.points, [2]float64{time.Duration(t.Elapsed).Seconds(), rate})
+			}
+			timeline = append(timeline, series)
+		}
+	}
+	if len(timeline) > 0 {
+		data.Timeline = lineChart(timeline, nil, "Seconds into the run", unit)
+	}
+
+	for i := range sweeps {
+		data.Histograms = append(data.Histograms, reportHistogram{
+			Cell:  labels[i],
+			Chart: histogramChart(hists[i].logBins(histogramBins)),
+		})
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating HTML report: %w", err)
+	}
+	defer f.Close()
+	if err := reportTemplate.Execute(f, data); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	return f.Close()
+}
+
+// reportConfig lists the settings of a benchmark for the report.
+func reportConfig(cfg *Config, sweeps []ConcurrencySweep) [][2]string {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "AWS S3"
+	}
+	rows := [][2]string{
+		{"Command", cfg.Mode},
+		{"Endpoint", endpoint},
+		{"Region", cfg.Region},
+		{"Bucket", cfg.Bucket},
+	}
+	for _, r := range [][2]string{{"Key", cfg.Key}, {"Prefix", cfg.Prefix}, {"Manifest", cfg.Manifest}} {
+		if r[1] != "" {
+			rows = append(rows, r)
+		}
+	}
+	if cfg.Mode == modeMixed {
+		var mix []string
+		for _, w := range cfg.OpMix {
+			mix = append(mix, fmt.Sprintf("%s=%d", w.Op, w.Weight))
+		}
+		rows = append(rows,
+			[2]string{"Operation mix", strings.Join(mix, ", ")},
+			[2]string{"Object size", formatBytes(cfg.ObjectSize)},
+		)
+	} else {
+		var sizes []string
+		for _, s := range cfg.ChunkSizeList {
+			sizes = append(sizes, formatBytes(s))
+		}
+		rows = append(rows, [2]string{"Chunk sizes", strings.Join(sizes, ", ")})
+	}
+	var conc []string
+	for _, sw := range sweeps {
+		if c := strconv.Itoa(sw.Concurrency); !slices.Contains(conc, c) {
+			conc = append(conc, c)
+		}
+	}
+	rows = append(rows,
+		[2]string{"Concurrency", strings.Join(conc, ", ")},
+		[2]string{"Runs per level", strconv.Itoa(cfg.Runs)},
+	)
+	switch {
+	case cfg.WarmupDuration > 0:
+		rows = append(rows, [2]string{"Warm-up", formatDuration(cfg.WarmupDuration)})
+	case cfg.WarmupRuns > 0:
+		rows = append(rows, [2]string{"Warm-up", plural(cfg.WarmupRuns, "run")})
+	}
+	if cfg.Duration > 0 {
+		rows = append(rows, [2]string{"Duration per run", formatDuration(cfg.Duration)})
+	}
+	if cfg.AutoTune {
+		rows = append(rows, [2]string{"Auto-tune", fmt.Sprintf("min gain %g%%", cfg.TuneMinGain)})
+	}
+	rows = append(rows,
+		[2]string{"Retry", cfg.Retry.display()},
+		[2]string{"Error budget", cfg.ErrorBudget.String()},
+		[2]string{fmt.Sprintf("Transport (%d workers)", sweeps[0].Concurrency), strings.Join(transportDisplay(sweeps[0].Transport), "; ")},
+		[2]string{"s3bench", toolVersion()},
+	)
+	if host, err := os.Hostname(); err == nil {
+		rows = append(rows, [2]string{"Client host", host})
+	}
+	return rows
+}
+
+// chartBar is one bar of a bar chart, with an optional lo–hi whisker.
+type chartBar struct {
+	label         string
+	value, lo, hi float64
+}
+
+// chartSeries is one line of a line chart.
+type chartSeries struct {
+	name   string
+	points [][2]float64
+}
+
+// svgChart draws a chart with a y axis from zero. x values are mapped from
+// xMin..xMax onto the plot area.
+type svgChart struct {
+	b          strings.Builder
+	xMin, xMax float64
+	yMax       float64
+}
+
+// newSVGChart starts a chart and draws its y axis, grid and label.
+func newSVGChart(xMin, xMax, yMax float64, yLabel string) *svgChart {
+	step := niceStep(yMax, 5)
+	c := &svgChart{xMin: xMin, xMax: xMax, yMax: math.Max(math.Ceil(yMax/step)*step, step)}
+	fmt.Fprintf(&c.b, `<svg viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" role="img">`, chartWidth, chartHeight)
+	for v := 0.0; v <= c.yMax+step/2; v += step {
+		y := c.y(v)
+		fmt.Fprintf(&c.b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" class="grid"/>`, chartLeft, y, chartWidth-chartRight, y)
+		fmt.Fprintf(&c.b, `<text x="%d" y="%.1f" class="tick" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			chartLeft-6, y, formatTick(v, step))
+	}
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%d" class="axis"/>`,
+		chartLeft, chartHeight-chartBottom, chartWidth-chartRight, chartHeight-chartBottom)
+	fmt.Fprintf(&c.b, `<text transform="translate(14 %d) rotate(-90)" class="label" text-anchor="middle">%s</text>`,
+		(chartTop+chartHeight-chartBottom)/2, html.EscapeString(yLabel))
+	return c
+}
+
+func (c *svgChart) x(v float64) float64 {
+	width := float64(chartWidth - chartLeft - chartRight)
+	if c.xMax == c.xMin {
+		return chartLeft + width/2
+	}
+	return chartLeft + (v-c.xMin)/(c.xMax-c.xMin)*width
+}
+
+func (c *svgChart) y(v float64) float64 {
+	height := float64(chartHeight - chartTop - chartBottom)
+	return float64(chartHeight-chartBottom) - v/c.yMax*height
+}
+
+// categories labels the x axis with one label per unit, tilted when crowded.
+func (c *svgChart) categories(labels []string) {
+	for i, l := range labels {
+		x := c.x(float64(i) + 0.5)
+		y := chartHeight - chartBottom + 16
+		if len(labels) > 8 {
+			fmt.Fprintf(&c.b, `<text transform="translate(%.1f %d) rotate(-30)" class="tick" text-anchor="end">%s</text>`,
+				x, y, html.EscapeString(l))
+		} else {
+			fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" class="tick" text-anchor="middle">%s</text>`, x, y, html.EscapeString(l))
+		}
+	}
+}
+
+// html finishes the chart, adding a legend for series when there is more
+// than one.
+func (c *svgChart) html(series []chartSeries) template.HTML {
+	c.b.WriteString("</svg>")
+	if len(series) > 1 {
+		c.b.WriteString(`<div class="legend">`)
+		for i, s := range series {
+			fmt.Fprintf(&c.b, `<span><i style="background:%s"></i>%s</span>`,
+				reportPalette[i%len(reportPalette)], html.EscapeString(s.name))
+		}
+		c.b.WriteString(`</div>`)
+	}
+	return template.HTML(c.b.String())
+}
+
+// barChart draws one bar per entry with its value above it.
+func barChart(bars []chartBar, yLabel string) template.HTML {
+	yMax := 0.0
+	labels := make([]string, len(bars))
+	for i, b := range bars {
+		yMax = math.Max(yMax, math.Max(b.value, b.hi))
+		labels[i] = b.label
+	}
+	c := newSVGChart(0, float64(len(bars)), yMax, yLabel)
+	for i, b := range bars {
+		x0, x1 := c.x(float64(i)+0.2), c.x(float64(i)+0.8)
+		fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %.1f</title></rect>`,
+			x0, c.y(b.value), x1-x0, c.y(0)-c.y(b.value), reportPalette[0], html.EscapeString(b.label), b.value)
+		mid := (x0 + x1) / 2
+		if b.hi > b.lo {
+			fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" class="whisker"/>`, mid, c.y(b.lo), mid, c.y(b.hi))
+		}
+		fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" class="value" text-anchor="middle">%.1f</text>`,
+			mid, c.y(math.Max(b.value, b.hi))-5, b.value)
+	}
+	c.categories(labels)
+	return c.html(nil)
+}
+
+// lineChart draws series of points. With categories the x values are
+// positions among them; otherwise the x axis is numeric and labelled xLabel.
+func lineChart(series []chartSeries, categories []string, xLabel, yLabel string) template.HTML {
+	xMax, yMax := 0.0, 0.0
+	for _, s := range series {
+		for _, p := range s.points {
+			xMax, yMax = math.Max(xMax, p[0]), math.Max(yMax, p[1])
+		}
+	}
+	if categories != nil {
+		xMax = float64(len(categories))
+	}
+	c := newSVGChart(0, xMax, yMax, yLabel)
+	if categories != nil {
+		c.categories(categories)
+	} else {
+		step := niceStep(xMax, 8)
+		for v := 0.0; v <= xMax+step/2; v += step {
+			fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" class="tick" text-anchor="middle">%s</text>`,
+				c.x(v), chartHeight-chartBottom+16, formatTick(v, step))
+		}
+		fmt.Fprintf(&c.b, `<text x="%d" y="%d" class="label" text-anchor="middle">%s</text>`,
+			(chartLeft+chartWidth-chartRight)/2, chartHeight-12, html.EscapeString(xLabel))
+	}
+	for i, s := range series {
+		color := reportPalette[i%len(reportPalette)]
+		points := make([]string, len(s.points))
+		for j, p := range s.points {
+			points[j] = fmt.Sprintf("%.1f,%.1f", c.x(p[0]), c.y(p[1]))
+		}
+		fmt.Fprintf(&c.b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"><title>%s</title></polyline>`,
+			strings.Join(points, " "), color, html.EscapeString(s.name))
+		if len(s.points) < 20 {
+			for _, p := range points {
+				x, y, _ := strings.Cut(p, ",")
+				fmt.Fprintf(&c.b, `<circle cx="%s" cy="%s" r="3" fill="%s"/>`, x, y, color)
+			}
+		}
+	}
+	return c.html(series)
+}
+
+// histogramChart draws latency bins as adjoining bars, with the latency at
+// the start of every eighth bin on the x axis.
+func histogramChart(bins []latencyBin) template.HTML {
+	yMax := 0.0
+	for _, b := range bins {
+		yMax = math.Max(yMax, float64(b.Count))
+	}
+	c := newSVGChart(0, float64(len(bins)), yMax, "Chunks")
+	for i, b := range bins {
+		x0, x1 := c.x(float64(i)), c.x(float64(i+1))
+		if b.Count > 0 {
+			fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s – %s: %d</title></rect>`,
+				x0, c.y(float64(b.Count)), math.Max(x1-x0-1, 1), c.y(0)-c.y(float64(b.Count)), reportPalette[0],
+				formatDuration(b.Lo), formatDuration(b.Hi), b.Count)
+		}
+		if i%8 == 0 || i == len(bins)-1 {
+			at, d := x0, b.Lo
+			if i == len(bins)-1 {
+				at, d = x1, b.Hi
+			}
+			fmt.Fprintf(&c.b, `<text x="%.1f" y="%d" class="tick" text-anchor="middle">%s</text>`,
+				at, chartHeight-chartBottom+16, formatDuration(d))
+		}
+	}
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d" class="label" text-anchor="middle">Chunk latency (log scale)</text>`,
+		(chartLeft+chartWidth-chartRight)/2, chartHeight-12)
+	return c.html(nil)
+}
+
+// niceStep returns a round spacing that splits 0..limit into about n steps.
+func niceStep(limit float64, n int) float64 {
+	if limit <= 0 {
+		return 1
+	}
+	raw := limit / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*mag {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+// formatTick formats an axis value with as many decimals as step needs.
+func formatTick(v, step float64) string {
+	decimals := max(0, int(-math.Floor(math.Log10(step))))
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #111827; margin: 2em auto; max-width: 820px; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: 0; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #e5e7eb; padding-bottom: .2em; }
+h3 { font-size: 1em; margin: 1.2em 0 .3em; }
+.meta { color: #6b7280; margin-top: .2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .25em .6em; border-bottom: 1px solid #e5e7eb; vertical-align: top; }
+table.results td, table.results th { text-align: right; white-space: nowrap; }
+table.config th { width: 12em; color: #374151; font-weight: 600; }
+svg { width: 100%; height: auto; }
+svg .grid { stroke: #e5e7eb; }
+svg .axis { stroke: #9ca3af; }
+svg .whisker { stroke: #111827; stroke-width: 1.5; }
+svg .tick, svg .value { font-size: 11px; fill: #4b5563; }
+svg .label { font-size: 12px; fill: #374151; }
+.legend { font-size: 12px; color: #374151; }
+.legend span { display: inline-block; margin-right: 1.2em; }
+.legend i { display: inline-block; width: .9em; height: .9em; margin-right: .35em; vertical-align: -.1em; }
+.note { color: #6b7280; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{.Generated}}</p>
+
+<h2>Results</h2>
+<table class="results">
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h2>Throughput</h2>
+<p class="note">Mean of the measured runs; the whiskers span the slowest to the fastest run.</p>
+{{.Throughput}}
+
+<h2>Latency percentiles</h2>
+{{.Latency}}
+
+<h2>Throughput timeline</h2>
+{{if .Timeline}}{{.Timeline}}{{else}}<p class="note">No timeline samples were recorded.</p>{{end}}
+
+<h2>Chunk latency histograms</h2>
+{{range .Histograms}}<h3>{{.Cell}}</h3>
+{{.Chart}}
+{{end}}
+<h2>Configuration</h2>
+<table class="config">
+{{range .Config}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -6,13 +6,15 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
-	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -21,140 +23,470 @@ import (
 )
 
 func main() {
-	cfg, err := parseConfig()
+	dispatch(os.Args[1:])
+}
+
+// runBenchmark runs the download, upload or mixed command.
+func runBenchmark(mode string, args []string) {
+	cfg, fs, err := parseConfig(mode, args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n\n", err)
-		flag.Usage()
-		os.Exit(1)
+		usageError(fs, err)
+	}
+	// Read the baseline first so a bad file fails before the run, not after.
+	var baseline []ConcurrencySweep
+	if cfg.Baseline != "" {
+		var op string
+		if baseline, op, err = readSweeps(cfg.Baseline); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if op != cfg.Mode {
+			log.Fatalf("--baseline %s holds %s results, not %s results", cfg.Baseline, op, cfg.Mode)
+		}
 	}
 
-	ctx := context.Background()
+	started := time.Now()
+	sweeps, tune, err := benchmark(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	result := benchmarkResult(cfg, started, sweeps, tune)
+	cells := result.benchmarkSweeps()
+	switch cfg.Format {
+	case formatJSON:
+		printJSON(result)
+	case formatCSV, formatTSV:
+		if err := writeRunTable(os.Stdout, cells, cfg.Percentiles, cfg.Format == formatTSV); err != nil {
+			log.Fatalf("writing results: %v", err)
+		}
+	}
+
+	// Sinks run once the results are out locally, so a network failure
+	// cannot lose them.
+	pushResults(context.Background(), &http.Client{Timeout: cfg.PushTimeout}, cfg, result, os.Stderr)
+
+	// The comparison goes to stderr when stdout carries machine-readable results.
+	if baseline != nil {
+		w := io.Writer(os.Stdout)
+		if cfg.Quiet {
+			w = os.Stderr
+		}
+		fmt.Fprintf(w, "\nComparing this run (candidate) with %s (baseline)\n", cfg.Baseline)
+		if compareSweeps(w, baseline, cells, cfg.Thresholds) > 0 {
+			os.Exit(exitRegression)
+		}
+	}
+}
+
+// benchmark runs a download, upload or mixed benchmark and returns the sweeps
+// of its chunk size × concurrency grid, or the report of an --auto-tune search.
+// Text output is printed as it goes unless cfg.Quiet is set.
+func benchmark(ctx context.Context, cfg *Config) ([]ConcurrencySweep, *TuneReport, error) {
+	if cfg.Transport.InsecureSkipVerify {
+		warnInsecureTLS()
+	}
 
 	client, err := buildS3Client(ctx, cfg)
 	if err != nil {
-		log.Fatalf("building S3 client: %v", err)
+		return nil, nil, fmt.Errorf("building S3 client: %w", err)
 	}
 
-	// Discover object size once before timed runs.
-	objectSize, err := getObjectSize(ctx, client, cfg.Bucket, cfg.Key)
-	if err != nil {
-		log.Fatalf("cannot determine object size: %v", err)
+	var live liveCounters
+	if cfg.MetricsListen != "" {
+		stopMetrics, err := startMetricsServer(cfg, &live)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer stopMetrics()
+	}
+
+	// Discover object sizes once before timed runs. Uploads take their size
+	// from the payload instead of the (possibly not yet existing) object.
+	var objects []ObjectSpec
+	var payload io.ReaderAt
+	var chunks []ChunkSpec
+	switch cfg.Mode {
+	case modeUpload:
+		var size int64
+		var closePayload func() error
+		payload, size, closePayload, err = openPayload(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("preparing upload payload: %w", err)
+		}
+		defer closePayload()
+		objects = []ObjectSpec{{Key: cfg.Key, Size: size}}
+	case modeMixed:
+		// The mixed workload creates its own objects; the plan is a list of operations.
+		chunks = planOps(cfg)
+	default:
+		objects, err = resolveObjects(ctx, client, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot determine object size: %w", err)
+		}
+	}
+
+	var checks verifyPlan
+	if cfg.Verify {
+		checks, err = planVerification(ctx, client, cfg.Bucket, objects)
+		if err != nil {
+			return nil, nil, fmt.Errorf("planning verification: %w", err)
+		}
 	}
 
-	chunks := planChunks(objectSize, cfg.ChunkSize)
+	objectSize := totalObjectSize(objects)
 
-	if !cfg.JSONOutput {
+	if !cfg.Quiet {
 		fmt.Printf("s3bench\n")
+		fmt.Printf("  Mode:        %s\n", cfg.Mode)
 		fmt.Printf("  Endpoint:    %s\n", endpointDisplay(cfg))
-		fmt.Printf("  Object:      s3://%s/%s\n", cfg.Bucket, cfg.Key)
-		fmt.Printf("  Object size: %s\n", formatBytes(objectSize))
-		fmt.Printf("  Chunk size:  %s  (%d chunks)\n", formatBytes(cfg.ChunkSize), len(chunks))
-		fmt.Printf("  Concurrency: %s\n", formatConcurrencyList(cfg.ConcurrencyList))
+		if cfg.Mode == modeMixed {
+			fmt.Printf("  Prefix:      s3://%s/%s\n", cfg.Bucket, cfg.Prefix)
+			fmt.Printf("  Op mix:      %s\n", formatOpMix(cfg.OpMix))
+			fmt.Printf("  Object size: %s  (%d seed objects)\n", formatBytes(cfg.ObjectSize), cfg.SeedObjects)
+		} else {
+			fmt.Printf("  Object:      %s\n", objectsDisplay(cfg, len(objects)))
+			fmt.Printf("  Object size: %s\n", formatBytes(objectSize))
+			if len(cfg.ChunkSizeList) > 1 {
+				fmt.Printf("  Chunk size:  %s\n", formatChunkSizeList(cfg.ChunkSizeList))
+			} else {
+				fmt.Printf("  Chunk size:  %s  (%d chunks)\n", formatBytes(cfg.ChunkSize), len(planObjectChunks(objects, cfg.ChunkSize)))
+			}
+		}
+		if cfg.AutoTune {
+			fmt.Printf("  Concurrency: %s\n", tuneDisplay(cfg))
+		} else {
+			fmt.Printf("  Concurrency: %s\n", formatConcurrencyList(cfg.ConcurrencyList))
+		}
+		for i, line := range transportDisplay(cfg.Transport) {
+			label := ""
+			if i == 0 {
+				label = "Transport:"
+			}
+			fmt.Printf("  %-12s %s\n", label, line)
+		}
+		fmt.Printf("  Retries:     %s; error budget %s\n", cfg.Retry.display(), cfg.ErrorBudget)
 		fmt.Printf("  Runs:        %d per concurrency level\n", cfg.Runs)
-		if cfg.DiscardOutput {
+		switch {
+		case cfg.WarmupDuration > 0:
+			fmt.Printf("  Warm-up:     %s per concurrency level (excluded from results)\n", formatDuration(cfg.WarmupDuration))
+		case cfg.WarmupRuns > 0:
+			fmt.Printf("  Warm-up:     %s per concurrency level (excluded from results)\n", plural(cfg.WarmupRuns, "run"))
+		}
+		if cfg.Mode == modeMixed {
+			if cfg.Duration > 0 {
+				fmt.Printf("  Duration:    %s per run\n", formatDuration(cfg.Duration))
+			} else {
+				fmt.Printf("  Operations:  %d per run\n", cfg.Ops)
+			}
+		} else if cfg.Duration > 0 {
+			order := "sequential"
+			if cfg.RandomRanges {
+				order = "random"
+			}
+			fmt.Printf("  Duration:    %s per run (%s ranges)\n", formatDuration(cfg.Duration), order)
+		}
+		switch {
+		case cfg.Mode == modeUpload:
+			fmt.Printf("  Payload:     %s\n", payloadDisplay(cfg))
+		case cfg.DiscardOutput:
 			fmt.Printf("  Output:      discard\n")
-		} else {
+		default:
 			fmt.Printf("  Output:      %s\n", cfg.OutputFile)
 		}
+		if checks != nil {
+			fmt.Printf("  Verify:      %s\n", verifyDisplay(checks, objects))
+		}
 	}
 
-	// Prepare output file if writing is requested.
+	// Prepare output file if writing is requested. The file is pre-sized so
+	// every chunk can be written straight to its own offset as it arrives.
 	var outFile *os.File
-	if !cfg.DiscardOutput && cfg.OutputFile != "" {
+	if cfg.Mode == modeDownload && !cfg.DiscardOutput && cfg.OutputFile != "" {
 		outFile, err = os.Create(cfg.OutputFile)
 		if err != nil {
-			log.Fatalf("creating output file %q: %v", cfg.OutputFile, err)
+			return nil, nil, fmt.Errorf("creating output file %q: %w", cfg.OutputFile, err)
 		}
 		defer outFile.Close()
+		if err := outFile.Truncate(objectSize); err != nil {
+			return nil, nil, fmt.Errorf("sizing output file %q: %w", cfg.OutputFile, err)
+		}
+	}
+
+	// The mixed workload needs existing objects for GET, HEAD and DELETE. They
+	// are created once up front, untimed, and whatever remains is removed at the end.
+	var keyspace *opKeyspace
+	if cfg.Mode == modeMixed {
+		keyspace = newOpKeyspace(cfg.Prefix)
+		seedConc := slices.Max(cfg.ConcurrencyList)
+		if err := seedKeyspace(ctx, client, cfg, keyspace, seedConc); err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if err := cleanupKeyspace(ctx, client, cfg, keyspace, seedConc); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: cleaning up s3://%s/%s: %v\n", cfg.Bucket, cfg.Prefix, err)
+			}
+		}()
+	}
+
+	if cfg.AutoTune {
+		if cfg.Mode != modeMixed {
+			chunks = planObjectChunks(objects, cfg.ChunkSize)
+		}
+		// Without --duration a run never uses more workers than it has chunks.
+		limit := cfg.TuneMaxConc
+		if cfg.Duration == 0 {
+			limit = min(limit, len(chunks))
+		}
+		report, err := autoTune(cfg, limit, func(conc int) (ConcurrencySweep, error) {
+			return runCell(ctx, client, cfg, objects, chunks, payload, keyspace, outFile, checks, &live, conc, true)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if !cfg.Quiet {
+			printTuneReport(report)
+		}
+		if err := writeSweepFiles(cfg, report.Sweeps); err != nil {
+			return nil, nil, err
+		}
+		return nil, &report, nil
 	}
 
 	var sweeps []ConcurrencySweep
-	var progress atomic.Int64
+	multiChunk := len(cfg.ChunkSizeList) > 1
 	multiConc := len(cfg.ConcurrencyList) > 1
 
-	for _, conc := range cfg.ConcurrencyList {
-		if !cfg.JSONOutput {
-			if multiConc {
-				fmt.Printf("\n=== Concurrency: %d workers ===\n", conc)
-			}
+	// Every chunk size is run at every concurrency level. Each grid cell gets
+	// its own copy of the config so summaries record the chunk size they used.
+	for _, chunkSize := range cfg.ChunkSizeList {
+		cellCfg := *cfg
+		cellCfg.ChunkSize = chunkSize
+		if cfg.Mode != modeMixed {
+			chunks = planObjectChunks(objects, chunkSize)
 		}
 
-		// Re-plan chunks: chunk count doesn't change with concurrency,
-		// but we keep this here for clarity when concurrency > chunk count.
-		var runSummaries []RunSummary
-
-		for run := 1; run <= cfg.Runs; run++ {
-			// Allocate output buffers only when we need to write the result.
-			var outBufs [][]byte
-			if !cfg.DiscardOutput {
-				outBufs = make([][]byte, len(chunks))
+		for _, conc := range cfg.ConcurrencyList {
+			if !cfg.Quiet {
+				switch {
+				case multiChunk:
+					fmt.Printf("\n=== Chunk size: %s (%d chunks), concurrency: %d workers ===\n",
+						formatBytes(chunkSize), len(chunks), conc)
+				case multiConc:
+					fmt.Printf("\n=== Concurrency: %d workers ===\n", conc)
+				}
 			}
 
-			progress.Store(0)
+			sweep, err := runCell(ctx, client, &cellCfg, objects, chunks, payload, keyspace, outFile, checks, &live, conc, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			sweeps = append(sweeps, sweep)
 
-			var stopProgress func()
-			if !cfg.JSONOutput {
-				if cfg.Runs > 1 {
-					fmt.Printf("\nRun %d/%d\n", run, cfg.Runs)
-				}
-				stopProgress = startProgressReporter(objectSize, &progress)
+			if !cfg.Quiet && cfg.Runs > 1 {
+				printAggregateSummary(sweep.Summaries, cfg)
 			}
+		}
+	}
 
-			result, err := downloadObject(ctx, client, cfg, chunks, outBufs, &progress, conc)
+	switch {
+	case cfg.Quiet:
+	case multiChunk:
+		printMatrixReport(sweeps, cfg.ChunkSizeList, cfg.ConcurrencyList)
+	case multiConc:
+		printComparisonReport(sweeps)
+	}
+	if err := writeSweepFiles(cfg, sweeps); err != nil {
+		return nil, nil, err
+	}
+	return sweeps, nil, nil
+}
 
-			if stopProgress != nil {
-				stopProgress()
-			}
+// writeSweepFiles writes the --timeline-file, --histogram-file and
+// --chunks-file outputs.
+func writeSweepFiles(cfg *Config, sweeps []ConcurrencySweep) error {
+	if cfg.TimelineFile != "" {
+		if err := writeTimelineCSV(cfg.TimelineFile, sweeps); err != nil {
+			return err
+		}
+	}
+	if cfg.HistogramFile != "" {
+		if err := writeHistogramFile(cfg.HistogramFile, histogramRecords(sweeps)); err != nil {
+			return err
+		}
+	}
+	if cfg.ChunksFile != "" {
+		tsv := cfg.Format == formatTSV || strings.EqualFold(filepath.Ext(cfg.ChunksFile), ".tsv")
+		if err := writeChunksFile(cfg.ChunksFile, sweeps, tsv); err != nil {
+			return err
+		}
+	}
+	if cfg.TraceFile != "" {
+		if err := writeTraceFile(cfg.TraceFile, cfg.TraceFormat, sweeps); err != nil {
+			return err
+		}
+	}
+	if cfg.HTMLReport != "" {
+		if err := writeHTMLReport(cfg.HTMLReport, cfg, sweeps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			if err != nil {
-				log.Fatalf("concurrency=%d run %d failed: %v", conc, run, err)
-			}
+// runCell performs the warm-up and then cfg.Runs measured runs of one chunk
+// size at one concurrency level. Warm-up runs use the same settings, so the
+// connection pool, DNS and TLS sessions and server caches are warm when
+// measurement starts; their summaries are kept in the sweep but left out of
+// the aggregate. Any failed run ends the cell. quiet suppresses the per-run
+// text summaries, leaving only the live progress line.
+func runCell(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	objects []ObjectSpec,
+	chunks []ChunkSpec,
+	payload io.ReaderAt,
+	keyspace *opKeyspace,
+	outFile *os.File,
+	checks verifyPlan,
+	live *liveCounters,
+	conc int,
+	quiet bool,
+) (ConcurrencySweep, error) {
+
+	objectSize := totalObjectSize(objects)
+	if live.metrics != nil {
+		live.metrics.startCell(conc, cfg.ChunkSize)
+	}
 
-			summary := computeStats(result, cfg, objectSize, run, conc)
-			runSummaries = append(runSummaries, summary)
+	// runOnce performs run number run of total with runCfg, which differs from
+	// cfg only in its duration for a timed warm-up.
+	runOnce := func(runCfg *Config, run, total int, warmup bool) (RunSummary, error) {
+		// Assign through an io.WriterAt so discard mode passes a true nil.
+		var out io.WriterAt
+		if outFile != nil {
+			out = outFile
+		}
 
-			// Flush chunks in order to the output file.
-			if outFile != nil {
-				for _, buf := range outBufs {
-					if _, err := outFile.Write(buf); err != nil {
-						log.Fatalf("writing output file: %v", err)
-					}
-				}
-				if run < cfg.Runs {
-					if _, err := outFile.Seek(0, io.SeekStart); err != nil {
-						log.Fatalf("seeking output file: %v", err)
-					}
-				}
+		live.reset()
+
+		label := "run"
+		if warmup {
+			label = "warm-up run"
+		}
+
+		var stopProgress func()
+		if !cfg.Quiet {
+			if !quiet && (total > 1 || warmup) {
+				fmt.Printf("\n%s %d/%d\n", strings.ToUpper(label[:1])+label[1:], run, total)
 			}
+			if cfg.Mode == modeMixed {
+				stopProgress = startProgressReporter(int64(len(chunks)), runCfg.Duration, &live.Requests, true)
+			} else {
+				stopProgress = startProgressReporter(objectSize, runCfg.Duration, &live.Bytes, false)
+			}
+		}
+		var stopTimeline func() []TimelineSample
+		if cfg.TimelineInterval > 0 {
+			stopTimeline = startTimelineSampler(live, cfg.TimelineInterval)
+		}
+
+		var result DownloadResult
+		var err error
+		switch cfg.Mode {
+		case modeUpload:
+			result, err = uploadObject(ctx, client, runCfg, chunks, payload, live, conc)
+		case modeMixed:
+			result, err = runOps(ctx, client, runCfg, keyspace, chunks, live, conc)
+		default:
+			result, err = downloadObject(ctx, client, runCfg, chunks, out, live, checks, conc)
+		}
+
+		if stopProgress != nil {
+			stopProgress()
+		}
+		var timeline []TimelineSample
+		if stopTimeline != nil {
+			timeline = stopTimeline()
+		}
 
-			if !cfg.JSONOutput {
-				printRunSummary(summary, cfg)
+		if err != nil {
+			return RunSummary{}, fmt.Errorf("chunk-size=%s concurrency=%d %s %d failed: %w", formatBytes(cfg.ChunkSize), conc, label, run, err)
+		}
+
+		// Flush the page cache so the disk phase includes getting the data
+		// onto storage, not just into memory.
+		if outFile != nil {
+			syncStart := time.Now()
+			if err := outFile.Sync(); err != nil {
+				return RunSummary{}, fmt.Errorf("syncing output file: %w", err)
 			}
+			result.SyncTime = time.Since(syncStart)
 		}
 
-		agg := computeAggregate(runSummaries)
-		sweeps = append(sweeps, ConcurrencySweep{
-			Concurrency: conc,
-			Summaries:   runSummaries,
-			Aggregate:   agg,
-		})
+		summary := computeStats(result, runCfg, objects, run, conc)
+		summary.Warmup = warmup
+		summary.Timeline = timeline
+		if cfg.ChunksFile != "" || cfg.TraceFile != "" {
+			summary.chunks = chunkRows(result, chunks, cfg.Key)
+		}
+		if checks != nil {
+			summary.Verify = verifyRun(result, chunks, objects, checks, outFile)
+		}
+		if outFile != nil && summary.Errors.Failed > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %d chunks failed; %s is incomplete\n", summary.Errors.Failed, cfg.OutputFile)
+		}
 
-		if !cfg.JSONOutput && cfg.Runs > 1 {
-			printAggregateSummary(runSummaries, cfg)
+		if !quiet {
+			printRunSummary(summary, runCfg)
 		}
+		return summary, nil
 	}
 
-	if cfg.JSONOutput {
-		printJSONSweeps(sweeps)
-	} else if multiConc {
-		printComparisonReport(sweeps)
+	sweep := ConcurrencySweep{
+		Concurrency: conc,
+		ChunkSize:   cfg.ChunkSize,
+		Transport:   cfg.Transport,
+	}
+
+	warmCfg := *cfg
+	warmRuns := cfg.WarmupRuns
+	if cfg.WarmupDuration > 0 {
+		warmCfg.Duration = cfg.WarmupDuration
+		warmRuns = 1
+	}
+	for run := 1; run <= warmRuns; run++ {
+		summary, err := runOnce(&warmCfg, run, warmRuns, true)
+		if err != nil {
+			return sweep, err
+		}
+		sweep.Warmup = append(sweep.Warmup, summary)
+	}
+
+	for run := 1; run <= cfg.Runs; run++ {
+		summary, err := runOnce(cfg, run, cfg.Runs, false)
+		if err != nil {
+			return sweep, err
+		}
+		sweep.Summaries = append(sweep.Summaries, summary)
+	}
+	sweep.Aggregate = computeAggregate(sweep.Summaries, cfg.Percentiles)
+
+	if !quiet && !cfg.Quiet && len(sweep.Warmup) > 0 {
+		printWarmupComparison(sweep.Warmup, sweep.Summaries)
 	}
+	return sweep, nil
 }
 
 // buildS3Client constructs an S3 client from the program configuration.
 func buildS3Client(ctx context.Context, cfg *Config) (*s3.Client, error) {
+	httpClient, err := buildHTTPClient(cfg.Transport)
+	if err != nil {
+		return nil, fmt.Errorf("configuring HTTP transport: %w", err)
+	}
+
 	opts := []func(*awsconfig.LoadOptions) error{
 		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithHTTPClient(httpClient),
 	}
 
 	// Load credentials from the named AWS profile.
@@ -188,6 +520,12 @@ func buildS3Client(ctx context.Context, cfg *Config) (*s3.Client, error) {
 		})
 	}
 
+	if cfg.Transport.HTTP2 == http2Force {
+		s3Opts = append(s3Opts, func(o *s3.Options) {
+			o.HTTPClient = &http2OnlyClient{inner: o.HTTPClient}
+		})
+	}
+
 	return s3.NewFromConfig(awsCfg, s3Opts...), nil
 }
 
@@ -198,6 +536,22 @@ func endpointDisplay(cfg *Config) string {
 	return "AWS S3"
 }
 
+func payloadDisplay(cfg *Config) string {
+	if cfg.UploadFile != "" {
+		return cfg.UploadFile
+	}
+	return fmt.Sprintf("generated (seed %d)", cfg.Seed)
+}
+
+// formatChunkSizeList renders the --chunk-size list for the header.
+func formatChunkSizeList(sizes []int64) string {
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = formatBytes(size)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func formatConcurrencyList(list []int) string {
 	if len(list) == 1 {
 		return fmt.Sprintf("%d workers", list[0])
@@ -6,123 +6,343 @@ package main
 
 import (
 	"math"
-	"sort"
 	"time"
 )
 
 // LatencyStats holds percentile and aggregate statistics for chunk download latencies.
+// Percentiles lists the --percentiles values where they were requested.
 type LatencyStats struct {
-	Min  time.Duration `json:"min_ms"`
-	Max  time.Duration `json:"max_ms"`
-	Mean time.Duration `json:"mean_ms"`
-	P50  time.Duration `json:"p50_ms"`
-	P95  time.Duration `json:"p95_ms"`
-	P99  time.Duration `json:"p99_ms"`
+	Min         millis       `json:"min_ms"`
+	Max         millis       `json:"max_ms"`
+	Mean        millis       `json:"mean_ms"`
+	P50         millis       `json:"p50_ms"`
+	P95         millis       `json:"p95_ms"`
+	P99         millis       `json:"p99_ms"`
+	Percentiles []Percentile `json:"percentiles,omitempty"`
 }
 
 // RunSummary contains the aggregate benchmark results for a single run.
 type RunSummary struct {
-	RunNumber    int          `json:"run"`
-	ObjectSize   int64        `json:"object_size_bytes"`
-	TotalBytes   int64        `json:"total_bytes_downloaded"`
+	RunNumber    int              `json:"run"`
+	Warmup       bool             `json:"warmup,omitempty"`
+	Operation    string           `json:"operation"`
+	ObjectSize   int64            `json:"object_size_bytes"`
+	TotalBytes   int64            `json:"total_bytes"`
+	ChunkCount   int              `json:"chunk_count"`
+	ChunkSize    int64            `json:"chunk_size_bytes"`
+	Concurrency  int              `json:"concurrency"`
+	TotalTime    millis           `json:"total_time_ms"`
+	Duration     millis           `json:"duration_ms,omitempty"` // time budget for --duration runs
+	TTFB         millis           `json:"ttfb_ms"`               // of the first request to respond
+	MeanTTFB     millis           `json:"mean_ttfb_ms"`          // over every successful chunk or operation
+	ThroughputMB float64          `json:"throughput_mb_s"`
+	ThroughputGB float64          `json:"throughput_gb_s"`
+	ChunkLatency LatencyStats     `json:"chunk_latency"`
+	DiskWrite    *DiskWriteStats  `json:"disk_write,omitempty"`
+	ObjectCount  int              `json:"object_count"`
+	Objects      []ObjectSummary  `json:"objects,omitempty"` // per-object breakdown for multi-object runs
+	OpsPerSec    float64          `json:"ops_per_s,omitempty"`
+	Operations   []OpSummary      `json:"operations,omitempty"`    // per-operation breakdown for mixed runs
+	PutFallbacks int              `json:"put_fallbacks,omitempty"` // mixed: GETs, HEADs and DELETEs run as PUTs for want of an object
+	Phases       PhaseStats       `json:"http_phases"`
+	Errors       ErrorStats       `json:"errors"`
+	Verify       *VerifyStats     `json:"verify,omitempty"`
+	Timeline     []TimelineSample `json:"timeline,omitempty"` // sampled every --timeline-interval
+
+	// histograms holds the chunk latencies by operation, for merging across
+	// runs and for --histogram-file.
+	histograms map[string]*LatencyHistogram
+	// chunks holds every request of the run when --chunks-file is set.
+	chunks []chunkRow
+}
+
+// PhaseStats summarises the HTTP connection phases of every request in a run.
+// DNS, Connect and TLS only include requests that actually performed them, so
+// their percentiles describe new connections rather than being diluted by zeros.
+type PhaseStats struct {
+	NewConns     int          `json:"new_connections"`
+	ReusedConns  int          `json:"reused_connections"`
+	Overhead     LatencyStats `json:"client_overhead"`
+	DNS          LatencyStats `json:"dns"`
+	Connect      LatencyStats `json:"connect"`
+	TLS          LatencyStats `json:"tls"`
+	WroteRequest LatencyStats `json:"wrote_request"`
+	ServerWait   LatencyStats `json:"server_wait"`
+	FirstByte    LatencyStats `json:"first_byte"`
+}
+
+// OpSummary reports ops/s and latency for one operation type in a mixed run.
+type OpSummary struct {
+	Op           string       `json:"op"`
+	Count        int          `json:"count"`
+	TotalBytes   int64        `json:"total_bytes"`
+	OpsPerSec    float64      `json:"ops_per_s"`
+	ThroughputMB float64      `json:"throughput_mb_s"`
+	Latency      LatencyStats `json:"latency"`
+}
+
+// ObjectSummary reports the share of a multi-object run that went to one object.
+// Throughput is measured from the object's first chunk starting to its last
+// chunk finishing, so it reflects what that object saw while sharing workers.
+type ObjectSummary struct {
+	Key          string       `json:"key"`
+	Size         int64        `json:"size_bytes"`
+	TotalBytes   int64        `json:"total_bytes"`
 	ChunkCount   int          `json:"chunk_count"`
-	ChunkSize    int64        `json:"chunk_size_bytes"`
-	Concurrency  int          `json:"concurrency"`
-	TotalTime    time.Duration `json:"total_time_ms"`
-	TTFB         time.Duration `json:"ttfb_ms"`
+	Elapsed      millis       `json:"elapsed_ms"`
 	ThroughputMB float64      `json:"throughput_mb_s"`
-	ThroughputGB float64      `json:"throughput_gb_s"`
 	ChunkLatency LatencyStats `json:"chunk_latency"`
 }
 
-// ConcurrencySweep holds all runs for a single concurrency level.
+// DiskWriteStats separates the time chunks spent writing to the output file from
+// the time spent receiving from the network. Times are summed across workers.
+type DiskWriteStats struct {
+	NetworkTime millis  `json:"network_time_ms"`
+	WriteTime   millis  `json:"write_time_ms"`
+	SyncTime    millis  `json:"sync_time_ms"`
+	WriteShare  float64 `json:"write_share_pct"` // WriteTime as a percentage of all chunk time
+	WriteMB     float64 `json:"write_mb_s"`      // bytes / WriteTime: what one stream's disk writes sustain
+}
+
+// ConcurrencySweep holds all runs for one cell of the chunk-size × concurrency
+// grid. Without a --chunk-size list every sweep shares the same chunk size.
 type ConcurrencySweep struct {
-	Concurrency int
-	Summaries   []RunSummary
-	Aggregate   AggregateSummary
+	Concurrency int               `json:"concurrency"`
+	ChunkSize   int64             `json:"chunk_size_bytes"` // 0 for mixed runs
+	Summaries   []RunSummary      `json:"runs"`
+	Warmup      []RunSummary      `json:"warmup_runs,omitempty"` // excluded from Aggregate
+	Aggregate   AggregateSummary  `json:"aggregate"`
+	Transport   TransportSettings `json:"transport"` // effective HTTP transport settings used for these runs
 }
 
 // AggregateSummary holds min/max/mean throughput across multiple runs.
 type AggregateSummary struct {
-	Runs         int     `json:"runs"`
-	MinThroughputMB float64 `json:"min_throughput_mb_s"`
-	MaxThroughputMB float64 `json:"max_throughput_mb_s"`
+	Runs             int     `json:"runs"`
+	MinThroughputMB  float64 `json:"min_throughput_mb_s"`
+	MaxThroughputMB  float64 `json:"max_throughput_mb_s"`
 	MeanThroughputMB float64 `json:"mean_throughput_mb_s"`
-	MinThroughputGB float64 `json:"min_throughput_gb_s"`
-	MaxThroughputGB float64 `json:"max_throughput_gb_s"`
+	MinThroughputGB  float64 `json:"min_throughput_gb_s"`
+	MaxThroughputGB  float64 `json:"max_throughput_gb_s"`
 	MeanThroughputGB float64 `json:"mean_throughput_gb_s"`
+	MinOpsPerSec     float64 `json:"min_ops_per_s,omitempty"`
+	MaxOpsPerSec     float64 `json:"max_ops_per_s,omitempty"`
+	MeanOpsPerSec    float64 `json:"mean_ops_per_s,omitempty"`
+
+	// Latency is the chunk latency of all runs together, read from their
+	// merged histograms. Mixed runs report it per operation instead.
+	Latency *LatencyStats `json:"latency,omitempty"`
 }
 
-// computeStats builds a RunSummary from a completed DownloadResult.
-func computeStats(result DownloadResult, cfg *Config, objectSize int64, runNumber int, concurrency int) RunSummary {
+// computeStats builds a RunSummary from a completed DownloadResult. Chunks that
+// failed after all retries are counted in Errors but excluded from everything else.
+func computeStats(result DownloadResult, cfg *Config, objects []ObjectSpec, runNumber int, concurrency int) RunSummary {
+	objectSize := totalObjectSize(objects)
+	result, errStats := splitFailures(result)
+
 	var totalBytes int64
-	durations := make([]float64, 0, len(result.Chunks))
+	var chunkTime, writeTime, ttfbTime time.Duration
+	latency := newLatencyHistogram()
 
 	for _, c := range result.Chunks {
 		totalBytes += c.Size
-		durations = append(durations, float64(c.ElapsedTotal))
+		chunkTime += c.ElapsedTotal
+		ttfbTime += c.TTFB
+		writeTime += c.WriteTime
+		latency.Record(c.ElapsedTotal)
 	}
 
-	sort.Float64s(durations)
+	var meanTTFB time.Duration
+	if len(result.Chunks) > 0 {
+		meanTTFB = ttfbTime / time.Duration(len(result.Chunks))
+	}
 
-	n := len(durations)
-	sum := 0.0
-	for _, d := range durations {
-		sum += d
+	elapsed := result.TotalTime.Seconds()
+	var throughputMB, throughputGB float64
+	if elapsed > 0 {
+		throughputMB = float64(totalBytes) / (1 << 20) / elapsed
+		throughputGB = float64(totalBytes) / (1 << 30) / elapsed
+	}
+
+	var opsPerSec float64
+	var operations []OpSummary
+	var fallbacks int
+	histograms := map[string]*LatencyHistogram{cfg.Mode: latency}
+	if cfg.Mode == modeMixed {
+		if elapsed > 0 {
+			opsPerSec = float64(len(result.Chunks)) / elapsed
+		}
+		operations, histograms = computeOpStats(result, cfg.Percentiles)
+		for _, c := range result.Chunks {
+			if c.Fallback {
+				fallbacks++
+			}
+		}
+	}
+
+	var diskWrite *DiskWriteStats
+	if cfg.Mode == modeDownload && !cfg.DiscardOutput {
+		diskWrite = &DiskWriteStats{
+			NetworkTime: millis(chunkTime - writeTime),
+			WriteTime:   millis(writeTime),
+			SyncTime:    millis(result.SyncTime),
+		}
+		if chunkTime > 0 {
+			diskWrite.WriteShare = float64(writeTime) / float64(chunkTime) * 100
+		}
+		if writeTime > 0 {
+			diskWrite.WriteMB = float64(totalBytes) / (1 << 20) / writeTime.Seconds()
+		}
+	}
+
+	return RunSummary{
+		RunNumber:    runNumber,
+		Operation:    cfg.Mode,
+		ObjectSize:   objectSize,
+		TotalBytes:   totalBytes,
+		ChunkCount:   len(result.Chunks),
+		ChunkSize:    cfg.ChunkSize,
+		Concurrency:  concurrency,
+		TotalTime:    millis(result.TotalTime),
+		Duration:     millis(cfg.Duration),
+		TTFB:         millis(result.TTFB),
+		MeanTTFB:     millis(meanTTFB),
+		ThroughputMB: throughputMB,
+		ThroughputGB: throughputGB,
+		ChunkLatency: latency.Stats(cfg.Percentiles),
+		DiskWrite:    diskWrite,
+		ObjectCount:  len(objects),
+		Objects:      computeObjectStats(result, objects),
+		OpsPerSec:    opsPerSec,
+		Operations:   operations,
+		PutFallbacks: fallbacks,
+		Phases:       computePhaseStats(result),
+		Errors:       errStats,
+		histograms:   histograms,
 	}
+}
 
-	percentile := func(p float64) time.Duration {
-		if n == 0 {
-			return 0
+// computePhaseStats builds per-phase latency percentiles from each request's trace.
+func computePhaseStats(result DownloadResult) PhaseStats {
+	var stats PhaseStats
+	overhead, dns, connect, tlsHS := newLatencyHistogram(), newLatencyHistogram(), newLatencyHistogram(), newLatencyHistogram()
+	wroteRequest, serverWait, firstByte := newLatencyHistogram(), newLatencyHistogram(), newLatencyHistogram()
+	for _, c := range result.Chunks {
+		p := c.Phases
+		if p.Reused {
+			stats.ReusedConns++
+		} else {
+			stats.NewConns++
+		}
+		if p.DidDNS {
+			dns.Record(p.DNS)
 		}
-		idx := int(math.Ceil(p/100.0*float64(n))) - 1
-		if idx < 0 {
-			idx = 0
+		if p.DidConnect {
+			connect.Record(p.Connect)
 		}
-		if idx >= n {
-			idx = n - 1
+		if p.DidTLS {
+			tlsHS.Record(p.TLS)
 		}
-		return time.Duration(durations[idx])
+		overhead.Record(p.Overhead)
+		wroteRequest.Record(p.WroteRequest)
+		serverWait.Record(p.ServerWait)
+		firstByte.Record(p.FirstByte)
 	}
 
-	var minD, maxD, meanD time.Duration
-	if n > 0 {
-		minD = time.Duration(durations[0])
-		maxD = time.Duration(durations[n-1])
-		meanD = time.Duration(sum / float64(n))
+	stats.Overhead = overhead.Stats(nil)
+	stats.DNS = dns.Stats(nil)
+	stats.Connect = connect.Stats(nil)
+	stats.TLS = tlsHS.Stats(nil)
+	stats.WroteRequest = wroteRequest.Stats(nil)
+	stats.ServerWait = serverWait.Stats(nil)
+	stats.FirstByte = firstByte.Stats(nil)
+	return stats
+}
+
+// computeOpStats breaks a mixed run down by operation type and returns the
+// latency histogram of each. Rates are measured over the whole run, since
+// every operation type shares the same workers.
+func computeOpStats(result DownloadResult, percentiles []float64) ([]OpSummary, map[string]*LatencyHistogram) {
+	histograms := map[string]*LatencyHistogram{}
+	bytes := map[string]int64{}
+	for _, c := range result.Chunks {
+		h := histograms[c.Op]
+		if h == nil {
+			h = newLatencyHistogram()
+			histograms[c.Op] = h
+		}
+		h.Record(c.ElapsedTotal)
+		bytes[c.Op] += c.Size
 	}
 
 	elapsed := result.TotalTime.Seconds()
-	var throughputMB, throughputGB float64
-	if elapsed > 0 {
-		throughputMB = float64(totalBytes) / (1 << 20) / elapsed
-		throughputGB = float64(totalBytes) / (1 << 30) / elapsed
+	var summaries []OpSummary
+	for _, op := range validOps {
+		h, ok := histograms[op]
+		if !ok {
+			continue
+		}
+		count := int(h.Count())
+		sum := OpSummary{Op: op, Count: count, TotalBytes: bytes[op]}
+		if elapsed > 0 {
+			sum.OpsPerSec = float64(count) / elapsed
+			sum.ThroughputMB = float64(bytes[op]) / (1 << 20) / elapsed
+		}
+		sum.Latency = h.Stats(percentiles)
+		summaries = append(summaries, sum)
 	}
+	return summaries, histograms
+}
 
-	return RunSummary{
-		RunNumber:   runNumber,
-		ObjectSize:  objectSize,
-		TotalBytes:  totalBytes,
-		ChunkCount:  len(result.Chunks),
-		ChunkSize:   cfg.ChunkSize,
-		Concurrency: concurrency,
-		TotalTime:   result.TotalTime,
-		TTFB:        result.TTFB,
-		ThroughputMB: throughputMB,
-		ThroughputGB: throughputGB,
-		ChunkLatency: LatencyStats{
-			Min:  minD,
-			Max:  maxD,
-			Mean: meanD,
-			P50:  percentile(50),
-			P95:  percentile(95),
-			P99:  percentile(99),
-		},
+// computeObjectStats breaks a multi-object run down per object, in the order
+// the objects were listed. It returns nil for single-object runs.
+func computeObjectStats(result DownloadResult, objects []ObjectSpec) []ObjectSummary {
+	if len(objects) < 2 {
+		return nil
 	}
+
+	type span struct {
+		first, last time.Time
+		latency     *LatencyHistogram
+		bytes       int64
+	}
+	spans := make(map[string]*span, len(objects))
+	for _, c := range result.Chunks {
+		sp := spans[c.Key]
+		if sp == nil {
+			sp = &span{first: c.StartTime, latency: newLatencyHistogram()}
+			spans[c.Key] = sp
+		}
+		end := c.StartTime.Add(c.ElapsedTotal)
+		if c.StartTime.Before(sp.first) {
+			sp.first = c.StartTime
+		}
+		if end.After(sp.last) {
+			sp.last = end
+		}
+		sp.bytes += c.Size
+		sp.latency.Record(c.ElapsedTotal)
+	}
+
+	summaries := make([]ObjectSummary, 0, len(objects))
+	for _, obj := range objects {
+		sum := ObjectSummary{Key: obj.Key, Size: obj.Size}
+		if sp := spans[obj.Key]; sp != nil {
+			sum.TotalBytes = sp.bytes
+			sum.ChunkCount = int(sp.latency.Count())
+			sum.Elapsed = millis(sp.last.Sub(sp.first))
+			if sum.Elapsed > 0 {
+				sum.ThroughputMB = float64(sp.bytes) / (1 << 20) / time.Duration(sum.Elapsed).Seconds()
+			}
+			sum.ChunkLatency = sp.latency.Stats(nil)
+		}
+		summaries = append(summaries, sum)
+	}
+	return summaries
 }
 
-// computeAggregate summarises throughput statistics across multiple runs.
-func computeAggregate(summaries []RunSummary) AggregateSummary {
+// computeAggregate summarises throughput statistics across multiple runs, and
+// their chunk latency with the given percentiles.
+func computeAggregate(summaries []RunSummary, percentiles []float64) AggregateSummary {
 	if len(summaries) == 0 {
 		return AggregateSummary{}
 	}
@@ -130,6 +350,9 @@ func computeAggregate(summaries []RunSummary) AggregateSummary {
 	minMB := summaries[0].ThroughputMB
 	maxMB := summaries[0].ThroughputMB
 	sumMB := 0.0
+	minOps := summaries[0].OpsPerSec
+	maxOps := summaries[0].OpsPerSec
+	sumOps := 0.0
 
 	for _, s := range summaries {
 		if s.ThroughputMB < minMB {
@@ -139,11 +362,14 @@ func computeAggregate(summaries []RunSummary) AggregateSummary {
 			maxMB = s.ThroughputMB
 		}
 		sumMB += s.ThroughputMB
+		minOps = math.Min(minOps, s.OpsPerSec)
+		maxOps = math.Max(maxOps, s.OpsPerSec)
+		sumOps += s.OpsPerSec
 	}
 
 	meanMB := sumMB / float64(len(summaries))
 
-	return AggregateSummary{
+	agg := AggregateSummary{
 		Runs:             len(summaries),
 		MinThroughputMB:  minMB,
 		MaxThroughputMB:  maxMB,
@@ -151,5 +377,29 @@ func computeAggregate(summaries []RunSummary) AggregateSummary {
 		MinThroughputGB:  minMB / 1024,
 		MaxThroughputGB:  maxMB / 1024,
 		MeanThroughputGB: meanMB / 1024,
+		MinOpsPerSec:     minOps,
+		MaxOpsPerSec:     maxOps,
+		MeanOpsPerSec:    sumOps / float64(len(summaries)),
+	}
+	if op := summaries[0].Operation; op != modeMixed {
+		if h := mergeHistograms(summaries)[op]; h != nil {
+			latency := h.Stats(percentiles)
+			agg.Latency = &latency
+		}
+	}
+	return agg
+}
+
+// mergeHistograms merges the latency histograms of runs by operation.
+func mergeHistograms(summaries []RunSummary) map[string]*LatencyHistogram {
+	merged := map[string]*LatencyHistogram{}
+	for _, s := range summaries {
+		for op, h := range s.histograms {
+			if merged[op] == nil {
+				merged[op] = newLatencyHistogram()
+			}
+			merged[op].Merge(h)
+		}
 	}
+	return merged
 }
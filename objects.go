@@ -0,0 +1,152 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectSpec identifies one object in the workload and its size.
+type ObjectSpec struct {
+	Key  string
+	Size int64
+}
+
+// resolveObjects builds the list of objects to read from --key, --prefix or
+// --manifest. Exactly one of them is set (enforced by parseConfig).
+func resolveObjects(ctx context.Context, client *s3.Client, cfg *Config) ([]ObjectSpec, error) {
+	switch {
+	case cfg.Prefix != "":
+		return listPrefix(ctx, client, cfg.Bucket, cfg.Prefix)
+	case cfg.Manifest != "":
+		keys, err := readManifest(cfg.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		objects := make([]ObjectSpec, 0, len(keys))
+		for _, key := range keys {
+			size, err := getObjectSize(ctx, client, cfg.Bucket, key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			objects = append(objects, ObjectSpec{Key: key, Size: size})
+		}
+		return objects, nil
+	default:
+		size, err := getObjectSize(ctx, client, cfg.Bucket, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return []ObjectSpec{{Key: cfg.Key, Size: size}}, nil
+	}
+}
+
+// listPrefix expands a key prefix into the non-empty objects beneath it using
+// ListObjectsV2. Sizes come from the listing, so no per-object HEAD is needed.
+func listPrefix(ctx context.Context, client *s3.Client, bucket, prefix string) ([]ObjectSpec, error) {
+	var objects []ObjectSpec
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ListObjectsV2 failed: %w", err)
+		}
+		for _, obj := range page.Contents {
+			// Skip zero-byte objects such as folder markers: there is nothing to read.
+			if aws.ToInt64(obj.Size) == 0 {
+				continue
+			}
+			objects = append(objects, ObjectSpec{Key: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size)})
+		}
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects found under s3://%s/%s", bucket, prefix)
+	}
+	return objects, nil
+}
+
+// readManifest reads one object key per line. Blank lines and lines starting
+// with '#' are ignored.
+func readManifest(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening manifest: %w", err)
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("manifest %q contains no keys", path)
+	}
+	return keys, nil
+}
+
+// planObjectChunks plans chunks for every object and interleaves them
+// round-robin, so the worker pool spreads concurrent GETs across all objects
+// rather than draining one object at a time. Indexes are global across objects.
+func planObjectChunks(objects []ObjectSpec, chunkSize int64) []ChunkSpec {
+	perObject := make([][]ChunkSpec, len(objects))
+	total := 0
+	for i, obj := range objects {
+		perObject[i] = planChunks(obj.Size, chunkSize)
+		total += len(perObject[i])
+	}
+
+	chunks := make([]ChunkSpec, 0, total)
+	for round := 0; len(chunks) < total; round++ {
+		for i, objChunks := range perObject {
+			if round >= len(objChunks) {
+				continue
+			}
+			c := objChunks[round]
+			c.Index = len(chunks)
+			c.Key = objects[i].Key
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+// totalObjectSize returns the combined size of all objects.
+func totalObjectSize(objects []ObjectSpec) int64 {
+	var total int64
+	for _, obj := range objects {
+		total += obj.Size
+	}
+	return total
+}
+
+// objectsDisplay describes the workload's objects for headers and summaries.
+func objectsDisplay(cfg *Config, objectCount int) string {
+	switch {
+	case cfg.Prefix != "":
+		return fmt.Sprintf("s3://%s/%s*  (%d objects)", cfg.Bucket, cfg.Prefix, objectCount)
+	case cfg.Manifest != "":
+		return fmt.Sprintf("%d objects in s3://%s from %s", objectCount, cfg.Bucket, cfg.Manifest)
+	default:
+		return fmt.Sprintf("s3://%s/%s", cfg.Bucket, cfg.Key)
+	}
+}
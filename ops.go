@@ -0,0 +1,368 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Operation names used by the mixed workload and in per-operation results.
+const (
+	opGet    = "get"
+	opPut    = "put"
+	opHead   = "head"
+	opDelete = "delete"
+	opList   = "list"
+)
+
+// validOps lists the operations accepted by --op-mix, in display order.
+var validOps = []string{opGet, opPut, opHead, opDelete, opList}
+
+// opsCycleLength is the length of the operation plan cycled by --duration runs.
+const opsCycleLength = 1000
+
+// OpWeight is one entry of the operation mix, e.g. get=70.
+type OpWeight struct {
+	Op     string
+	Weight int
+}
+
+// parseOpMix parses a mix such as "get=70,put=20,delete=10". Weights are
+// relative and need not add up to 100.
+func parseOpMix(s string) ([]OpWeight, error) {
+	var mix []OpWeight
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, rawWeight, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q (want op=weight)", part)
+		}
+		if !isValidOp(name) {
+			return nil, fmt.Errorf("unknown operation %q (want one of %s)", name, strings.Join(validOps, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("operation %q listed twice", name)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight in %q (must be a non-negative integer)", part)
+		}
+		seen[name] = true
+		if w > 0 {
+			mix = append(mix, OpWeight{Op: name, Weight: w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("at least one operation needs a positive weight")
+	}
+	return mix, nil
+}
+
+func isValidOp(name string) bool {
+	for _, op := range validOps {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatOpMix renders the mix as percentages for the header printout.
+func formatOpMix(mix []OpWeight) string {
+	total := 0
+	for _, w := range mix {
+		total += w.Weight
+	}
+	parts := make([]string, len(mix))
+	for i, w := range mix {
+		parts[i] = fmt.Sprintf("%.0f%% %s", float64(w.Weight)/float64(total)*100, w.Op)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// planOps builds the operation plan for one run as ChunkSpecs so it can be fed
+// through the same worker pool as chunk transfers. Each operation appears in
+// exact proportion to its weight, shuffled with a seeded source so runs are
+// reproducible. Duration-based runs cycle a fixed-length plan instead.
+func planOps(cfg *Config) []ChunkSpec {
+	count := cfg.Ops
+	if cfg.Duration > 0 {
+		count = opsCycleLength
+	}
+
+	total := 0
+	for _, w := range cfg.OpMix {
+		total += w.Weight
+	}
+
+	// Largest-remainder apportionment so the counts add up to exactly count.
+	counts := make([]int, len(cfg.OpMix))
+	type rem struct {
+		idx  int
+		frac float64
+	}
+	rems := make([]rem, len(cfg.OpMix))
+	assigned := 0
+	for i, w := range cfg.OpMix {
+		exact := float64(count) * float64(w.Weight) / float64(total)
+		counts[i] = int(exact)
+		assigned += counts[i]
+		rems[i] = rem{idx: i, frac: exact - float64(counts[i])}
+	}
+	sort.SliceStable(rems, func(a, b int) bool { return rems[a].frac > rems[b].frac })
+	for i := 0; assigned < count; i++ {
+		counts[rems[i%len(rems)].idx]++
+		assigned++
+	}
+
+	ops := make([]ChunkSpec, 0, count)
+	for i, w := range cfg.OpMix {
+		for j := 0; j < counts[i]; j++ {
+			ops = append(ops, ChunkSpec{Op: w.Op, Size: cfg.ObjectSize})
+		}
+	}
+	rng := rand.New(rand.NewPCG(uint64(cfg.Seed), uint64(cfg.Seed)))
+	rng.Shuffle(len(ops), func(i, j int) { ops[i], ops[j] = ops[j], ops[i] })
+	for i := range ops {
+		ops[i].Index = i
+	}
+	return ops
+}
+
+// opKeyspace tracks the objects that currently exist under the workload prefix.
+// GET, HEAD and DELETE check a key out for exclusive use so a concurrent DELETE
+// can never remove an object another worker is reading.
+type opKeyspace struct {
+	prefix string
+	mu     sync.Mutex
+	keys   []string
+	nextID int
+}
+
+func newOpKeyspace(prefix string) *opKeyspace {
+	return &opKeyspace{prefix: prefix}
+}
+
+// newKey allocates a fresh key for a PUT.
+func (ks *opKeyspace) newKey() string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.nextID++
+	return fmt.Sprintf("%sobj-%08d", ks.prefix, ks.nextID)
+}
+
+// checkout removes a random key from the keyspace. ok is false if none exist.
+func (ks *opKeyspace) checkout() (key string, ok bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if len(ks.keys) == 0 {
+		return "", false
+	}
+	i := rand.IntN(len(ks.keys))
+	key = ks.keys[i]
+	ks.keys[i] = ks.keys[len(ks.keys)-1]
+	ks.keys = ks.keys[:len(ks.keys)-1]
+	return key, true
+}
+
+// take checks out key itself. ok is false if it is not in the keyspace.
+func (ks *opKeyspace) take(key string) (ok bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	i := slices.Index(ks.keys, key)
+	if i < 0 {
+		return false
+	}
+	ks.keys[i] = ks.keys[len(ks.keys)-1]
+	ks.keys = ks.keys[:len(ks.keys)-1]
+	return true
+}
+
+// add returns a key to the keyspace after a PUT or a completed read.
+func (ks *opKeyspace) add(key string) {
+	ks.mu.Lock()
+	ks.keys = append(ks.keys, key)
+	ks.mu.Unlock()
+}
+
+// all returns every key currently in the keyspace.
+func (ks *opKeyspace) all() []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return append([]string(nil), ks.keys...)
+}
+
+// seedKeyspace uploads cfg.SeedObjects objects so the first GET, HEAD and
+// DELETE operations have something to act on. It is not timed.
+func seedKeyspace(ctx context.Context, client *s3.Client, cfg *Config, ks *opKeyspace, concurrency int) error {
+	if cfg.SeedObjects == 0 {
+		return nil
+	}
+	seed := make([]ChunkSpec, cfg.SeedObjects)
+	for i := range seed {
+		seed[i] = ChunkSpec{Index: i, Op: opPut, Size: cfg.ObjectSize}
+	}
+	seedCfg := *cfg
+	seedCfg.Duration = 0
+	_, err := runOps(ctx, client, &seedCfg, ks, seed, new(liveCounters), concurrency)
+	if err != nil {
+		return fmt.Errorf("seeding %d objects: %w", cfg.SeedObjects, err)
+	}
+	return nil
+}
+
+// cleanupKeyspace deletes every object the workload left behind. Each DELETE
+// checks out a key, so planning one DELETE per remaining key empties the keyspace.
+func cleanupKeyspace(ctx context.Context, client *s3.Client, cfg *Config, ks *opKeyspace, concurrency int) error {
+	remaining := len(ks.all())
+	if remaining == 0 {
+		return nil
+	}
+	del := make([]ChunkSpec, remaining)
+	for i := range del {
+		del[i] = ChunkSpec{Index: i, Op: opDelete}
+	}
+	cleanCfg := *cfg
+	cleanCfg.Duration = 0
+	_, err := runOps(ctx, client, &cleanCfg, ks, del, new(liveCounters), concurrency)
+	return err
+}
+
+// runOps executes an operation plan with the shared worker pool.
+// live counts the bytes moved by GETs and PUTs and the operations finished.
+func runOps(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	ks *opKeyspace,
+	ops []ChunkSpec,
+	live *liveCounters,
+	concurrency int,
+) (DownloadResult, error) {
+
+	return runWorkerPool(cfg, ops, concurrency, live, func(op ChunkSpec) ChunkResult {
+		return runOp(ctx, client, cfg, ks, op, &live.Bytes)
+	})
+}
+
+// runOp performs one small-object operation and records its latency.
+// GET, HEAD and DELETE fall back to a PUT if the keyspace is momentarily
+// empty; the result is then reported as a PUT and counted as a fallback.
+func runOp(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	ks *opKeyspace,
+	op ChunkSpec,
+	progress *atomic.Int64,
+) ChunkResult {
+
+	// op.Key is set when retrying: a PUT reuses its key, so a failed attempt
+	// that reached the server leaves no untracked object, and a GET, HEAD or
+	// DELETE takes its object back unless another worker got to it first.
+	kind, key := op.Op, op.Key
+	fallback := false
+	switch kind {
+	case opGet, opHead, opDelete:
+		if key == "" || !ks.take(key) {
+			var ok bool
+			if key, ok = ks.checkout(); !ok {
+				kind, fallback = opPut, true
+			}
+		}
+	}
+	if kind == opPut && key == "" {
+		key = ks.newKey()
+	}
+
+	res := ChunkResult{Index: op.Index, Op: kind, Key: key, Fallback: fallback}
+	start := time.Now()
+	res.StartTime = start
+	ctx, rec := withPhaseTrace(ctx, start)
+
+	var err error
+	switch kind {
+	case opGet:
+		var resp *s3.GetObjectOutput
+		resp, err = client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(cfg.Bucket),
+			Key:    aws.String(key),
+		}, noSDKRetries)
+		if err == nil {
+			res.TTFB = time.Since(start)
+			res.Size, err = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		ks.add(key)
+
+	case opHead:
+		_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(cfg.Bucket),
+			Key:    aws.String(key),
+		}, noSDKRetries)
+		ks.add(key)
+
+	case opPut:
+		_, err = client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(cfg.Bucket),
+			Key:           aws.String(key),
+			ContentLength: aws.Int64(cfg.ObjectSize),
+			Body:          io.NewSectionReader(newPatternReader(cfg.Seed, cfg.ObjectSize, cfg.Compressibility), 0, cfg.ObjectSize),
+			Metadata:      uploadMetadata(cfg),
+		}, noSDKRetries)
+		if err == nil {
+			res.Size = cfg.ObjectSize
+			ks.add(key)
+		}
+
+	case opDelete:
+		_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(cfg.Bucket),
+			Key:    aws.String(key),
+		}, noSDKRetries)
+		if err != nil {
+			// The object may still exist; keep it for cleanupKeyspace.
+			ks.add(key)
+		}
+
+	case opList:
+		_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(cfg.Bucket),
+			Prefix: aws.String(ks.prefix),
+		}, noSDKRetries)
+	}
+
+	res.ElapsedTotal = time.Since(start)
+	res.Phases = rec.timings()
+	if res.TTFB == 0 {
+		res.TTFB = res.ElapsedTotal
+	}
+	if err != nil {
+		res.Err = fmt.Errorf("%s %s: %w", strings.ToUpper(kind), key, err)
+		return res
+	}
+	if progress != nil && res.Size > 0 {
+		progress.Add(res.Size)
+	}
+	return res
+}
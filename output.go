@@ -7,7 +7,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
 )
@@ -15,7 +18,10 @@ import (
 // startProgressReporter spawns a goroutine that prints a live transfer-rate line
 // every 200ms, overwriting itself with \r. Call the returned stop function when
 // the download finishes; it clears the line so subsequent output is clean.
-func startProgressReporter(totalBytes int64, progress *atomic.Int64) func() {
+// For time-budgeted runs pass the budget as window; percentage complete is then
+// measured against time rather than bytes. With ops set, progress and total
+// count operations instead of bytes, for mixed runs.
+func startProgressReporter(total int64, window time.Duration, progress *atomic.Int64, ops bool) func() {
 	done := make(chan struct{})
 	stopped := make(chan struct{})
 
@@ -26,30 +32,45 @@ func startProgressReporter(totalBytes int64, progress *atomic.Int64) func() {
 		defer ticker.Stop()
 
 		startTime := time.Now()
-		prevBytes := int64(0)
+		prevCount := int64(0)
 		prevTime := startTime
 
+		// count formats the progress; rate is measured over the last tick.
+		count, rate, verb := formatBytes, "MB/s", "transferred"
+		scale := float64(1 << 20)
+		if ops {
+			count = func(n int64) string { return fmt.Sprintf("%d ops", n) }
+			rate, verb, scale = "ops/s", "done", 1
+		}
+
 		print := func(now time.Time) {
 			cur := progress.Load()
 			interval := now.Sub(prevTime).Seconds()
 
-			var rateMB float64
+			var perSec float64
 			if interval > 0 {
-				rateMB = float64(cur-prevBytes) / (1 << 20) / interval
+				perSec = float64(cur-prevCount) / scale / interval
 			}
 
-			pct := 0.0
-			if totalBytes > 0 {
-				pct = float64(cur) / float64(totalBytes) * 100
-			}
 			elapsed := now.Sub(startTime)
 
-			line := fmt.Sprintf("  %s / %s  (%5.1f%%)   %8.1f MB/s   elapsed: %s",
-				formatBytes(cur), formatBytes(totalBytes), pct, rateMB, formatDuration(elapsed))
+			var line string
+			if window > 0 {
+				pct := math.Min(float64(elapsed)/float64(window)*100, 100)
+				line = fmt.Sprintf("  %s %s  (%5.1f%%)   %8.1f %s   elapsed: %s",
+					count(cur), verb, pct, perSec, rate, formatDuration(elapsed))
+			} else {
+				pct := 0.0
+				if total > 0 {
+					pct = float64(cur) / float64(total) * 100
+				}
+				line = fmt.Sprintf("  %s / %s  (%5.1f%%)   %8.1f %s   elapsed: %s",
+					count(cur), count(total), pct, perSec, rate, formatDuration(elapsed))
+			}
 			// %-80s pads to 80 chars so any shorter line fully overwrites a longer previous one.
 			fmt.Printf("\r%-80s", line)
 
-			prevBytes = cur
+			prevCount = cur
 			prevTime = now
 		}
 
@@ -73,45 +94,290 @@ func startProgressReporter(totalBytes int64, progress *atomic.Int64) func() {
 
 // printRunSummary prints a formatted text table for a single run.
 func printRunSummary(s RunSummary, cfg *Config) {
-	if cfg.JSONOutput {
-		return // JSON is handled in bulk at the end
+	if cfg.Quiet {
+		return // machine-readable formats are written in bulk at the end
 	}
 
-	fmt.Printf("\n=== Run %d ===\n", s.RunNumber)
-	fmt.Printf("  Object:       s3://%s/%s\n", cfg.Bucket, cfg.Key)
+	if s.Operation == modeMixed {
+		printMixedRunSummary(s, cfg)
+		return
+	}
+
+	fmt.Printf("\n=== %s ===\n", runTitle(s))
+	fmt.Printf("  Operation:    %s\n", s.Operation)
+	fmt.Printf("  Object:       %s\n", objectsDisplay(cfg, s.ObjectCount))
 	fmt.Printf("  Object size:  %s\n", formatBytes(s.ObjectSize))
 	fmt.Printf("  Chunk size:   %s  (%d chunks)\n", formatBytes(s.ChunkSize), s.ChunkCount)
-	fmt.Printf("  Concurrency:  %d workers\n\n", s.Concurrency)
+	fmt.Printf("  Concurrency:  %d workers\n", s.Concurrency)
+	if s.Duration > 0 {
+		fmt.Printf("  Time budget:  %s  (%d chunk reads, %.1f passes over the object)\n",
+			formatDuration(time.Duration(s.Duration)), s.ChunkCount, float64(s.TotalBytes)/float64(s.ObjectSize))
+	}
+	fmt.Println()
 
 	fmt.Printf("  Results:\n")
-	fmt.Printf("    Total time:        %s\n", formatDuration(s.TotalTime))
+	fmt.Printf("    Total time:        %s\n", formatDuration(time.Duration(s.TotalTime)))
 	fmt.Printf("    Total bytes:       %s\n", formatBytes(s.TotalBytes))
 	fmt.Printf("    Throughput:        %.1f MB/s  (%.3f GB/s)\n", s.ThroughputMB, s.ThroughputGB)
-	fmt.Printf("    Time to 1st byte:  %s\n\n", formatDuration(s.TTFB))
+	fmt.Printf("    Time to 1st byte:  %s\n", formatDuration(time.Duration(s.TTFB)))
+	fmt.Printf("    Mean TTFB:         %s\n\n", formatDuration(time.Duration(s.MeanTTFB)))
+
+	fmt.Printf("  Chunk latency (per-chunk %s time):\n", s.Operation)
+	printLatencyLines(s.ChunkLatency)
+
+	printPhaseStats(s.Phases)
+	printErrorStats(s.Errors)
+	printTimelineStats(s)
+	if s.Verify != nil {
+		printVerifyStats(*s.Verify)
+	}
+
+	if d := s.DiskWrite; d != nil {
+		fmt.Printf("\n  Disk write phase (summed across workers):\n")
+		fmt.Printf("    Network receive:   %s\n", formatDuration(time.Duration(d.NetworkTime)))
+		fmt.Printf("    Disk write:        %s  (%.1f%% of chunk time)\n", formatDuration(time.Duration(d.WriteTime)), d.WriteShare)
+		fmt.Printf("    Disk write rate:   %.1f MB/s per stream\n", d.WriteMB)
+		fmt.Printf("    Final fsync:       %s\n", formatDuration(time.Duration(d.SyncTime)))
+	}
+
+	if len(s.Objects) > 0 {
+		printObjectTable(s.Objects)
+	}
+}
+
+// printLatencyLines prints min, max, mean and the requested percentiles of a
+// latency distribution, one per line.
+func printLatencyLines(l LatencyStats) {
+	fmt.Printf("    %-8s%s\n", "Min:", formatDuration(time.Duration(l.Min)))
+	fmt.Printf("    %-8s%s\n", "Max:", formatDuration(time.Duration(l.Max)))
+	fmt.Printf("    %-8s%s\n", "Mean:", formatDuration(time.Duration(l.Mean)))
+	for _, p := range l.Percentiles {
+		fmt.Printf("    %-8s%s\n", percentileLabel(p.Percentile)+":", formatDuration(time.Duration(p.Value)))
+	}
+}
 
-	fmt.Printf("  Chunk latency (per-chunk download time):\n")
-	fmt.Printf("    Min:   %s\n", formatDuration(s.ChunkLatency.Min))
-	fmt.Printf("    Max:   %s\n", formatDuration(s.ChunkLatency.Max))
-	fmt.Printf("    Mean:  %s\n", formatDuration(s.ChunkLatency.Mean))
-	fmt.Printf("    P50:   %s\n", formatDuration(s.ChunkLatency.P50))
-	fmt.Printf("    P95:   %s\n", formatDuration(s.ChunkLatency.P95))
-	fmt.Printf("    P99:   %s\n", formatDuration(s.ChunkLatency.P99))
+// runTitle heads a run summary, marking warm-up runs so their numbers are not
+// mistaken for measurements.
+func runTitle(s RunSummary) string {
+	if s.Warmup {
+		return fmt.Sprintf("Warm-up run %d (excluded from results)", s.RunNumber)
+	}
+	return fmt.Sprintf("Run %d", s.RunNumber)
+}
+
+// printMixedRunSummary prints ops/s and latency per operation type for a mixed run.
+func printMixedRunSummary(s RunSummary, cfg *Config) {
+	fmt.Printf("\n=== %s ===\n", runTitle(s))
+	fmt.Printf("  Workload:     %s\n", formatOpMix(cfg.OpMix))
+	fmt.Printf("  Prefix:       s3://%s/%s\n", cfg.Bucket, cfg.Prefix)
+	fmt.Printf("  Object size:  %s\n", formatBytes(cfg.ObjectSize))
+	fmt.Printf("  Concurrency:  %d workers\n\n", s.Concurrency)
+
+	fmt.Printf("  Results:\n")
+	fmt.Printf("    Total time:        %s\n", formatDuration(time.Duration(s.TotalTime)))
+	fmt.Printf("    Operations:        %d\n", s.ChunkCount)
+	fmt.Printf("    Rate:              %.1f ops/s\n", s.OpsPerSec)
+	if s.PutFallbacks > 0 {
+		fmt.Printf("    PUT fallbacks:     %d  (GET, HEAD or DELETE found no object; counted as PUTs)\n", s.PutFallbacks)
+	}
+	fmt.Printf("    Throughput:        %.1f MB/s  (%s moved)\n\n", s.ThroughputMB, formatBytes(s.TotalBytes))
+
+	fmt.Printf("  Per-operation latency:\n")
+	fmt.Printf("    %-6s  %8s  %10s  %10s", "Op", "Count", "Ops/s", "Mean")
+	for _, p := range cfg.Percentiles {
+		fmt.Printf("  %10s", percentileLabel(p))
+	}
+	fmt.Printf("  %10s\n", "Max")
+	for _, op := range s.Operations {
+		fmt.Printf("    %-6s  %8d  %10.1f  %10s", op.Op, op.Count, op.OpsPerSec, formatDuration(time.Duration(op.Latency.Mean)))
+		for _, p := range op.Latency.Percentiles {
+			fmt.Printf("  %10s", formatDuration(time.Duration(p.Value)))
+		}
+		fmt.Printf("  %10s\n", formatDuration(time.Duration(op.Latency.Max)))
+	}
+
+	printPhaseStats(s.Phases)
+	printErrorStats(s.Errors)
+	printTimelineStats(s)
+}
+
+// printPhaseStats prints the HTTP phase breakdown. DNS, connect and TLS rows are
+// omitted when no request in the run opened a new connection.
+func printPhaseStats(p PhaseStats) {
+	fmt.Printf("\n  HTTP phases (%d new connections, %d reused):\n", p.NewConns, p.ReusedConns)
+	fmt.Printf("    %-16s  %10s  %10s  %10s  %10s\n", "Phase", "P50", "P95", "P99", "Max")
+	row := func(name string, l LatencyStats) {
+		fmt.Printf("    %-16s  %10s  %10s  %10s  %10s\n", name,
+			formatDuration(time.Duration(l.P50)), formatDuration(time.Duration(l.P95)), formatDuration(time.Duration(l.P99)), formatDuration(time.Duration(l.Max)))
+	}
+	row("Client/signing", p.Overhead)
+	if p.DNS.Max > 0 {
+		row("DNS", p.DNS)
+	}
+	if p.Connect.Max > 0 {
+		row("TCP connect", p.Connect)
+	}
+	if p.TLS.Max > 0 {
+		row("TLS handshake", p.TLS)
+	}
+	row("Request write", p.WroteRequest)
+	row("Server wait", p.ServerWait)
+	row("First byte", p.FirstByte)
+}
+
+// printErrorStats prints retries and failed chunks. Nothing is printed for a
+// run in which every request succeeded first time.
+func printErrorStats(e ErrorStats) {
+	if e.Retries == 0 && e.Failed == 0 {
+		return
+	}
+	fmt.Printf("\n  Errors:\n")
+	fmt.Printf("    Retries:           %d\n", e.Retries)
+	fmt.Printf("    Failed chunks:     %d  (excluded from the statistics above)\n", e.Failed)
+	fmt.Printf("    By status:         %s\n", formatStatusCounts(e.StatusCounts))
+}
+
+// printTimelineStats summarises a run's timeline: the range of the
+// per-interval throughput and the intervals in which requests were in flight
+// but no bytes moved. Uploads count their bytes as each part completes, so a
+// quiet interval there is not a stall and none are reported. The samples
+// themselves are in --json and --timeline-file.
+func printTimelineStats(s RunSummary) {
+	timeline := s.Timeline
+	if len(timeline) == 0 {
+		return
+	}
+	lo, hi := math.Inf(1), 0.0
+	stalls := 0
+	var prevBytes int64
+	for _, t := range timeline {
+		lo = math.Min(lo, t.ThroughputMB)
+		hi = math.Max(hi, t.ThroughputMB)
+		// Before the first bytes arrive, requests in flight are just waiting
+		// for their first byte, so only count stalls after data has flowed.
+		if prevBytes > 0 && t.Bytes == prevBytes && t.InFlight > 0 {
+			stalls++
+		}
+		prevBytes = t.Bytes
+	}
+	fmt.Printf("\n  Timeline (%d samples):\n", len(timeline))
+	fmt.Printf("    Interval rate:     %.1f – %.1f MB/s\n", lo, hi)
+	if s.Operation != modeUpload {
+		fmt.Printf("    Stalled intervals: %d  (requests in flight, no bytes moved)\n", stalls)
+	}
+}
+
+// printVerifyStats prints the --verify verdicts. Per-object lines are listed
+// only for objects that did not pass, plus a count of those that did.
+func printVerifyStats(v VerifyStats) {
+	passed := 0
+	var problems []ObjectVerification
+	for _, o := range v.Objects {
+		if o.Status == verifyOK {
+			passed++
+		} else {
+			problems = append(problems, o)
+		}
+	}
+	fmt.Printf("\n  Verification:\n")
+	fmt.Printf("    Ranges checked:    %d\n", v.ChunksVerified)
+	fmt.Printf("    Range mismatches:  %d\n", len(v.RangeMismatches))
+	fmt.Printf("    Objects OK:        %d of %d\n", passed, len(v.Objects))
+	for _, o := range problems {
+		fmt.Printf("    %-10s %s (%s): %s\n", strings.ToUpper(o.Status), truncateKey(o.Key, 40), o.Method, o.Detail)
+	}
+	for i, m := range v.RangeMismatches {
+		if i == maxObjectRows {
+			fmt.Printf("    ... %d more range mismatches (use --json for all)\n", len(v.RangeMismatches)-i)
+			break
+		}
+		fmt.Printf("    MISMATCH   %s bytes %d-%d: %s\n", truncateKey(m.Key, 40), m.RangeStart, m.RangeEnd, m.Detail)
+	}
+}
+
+// maxObjectRows caps the per-object table in text output; JSON always has every object.
+const maxObjectRows = 20
+
+// printObjectTable prints the per-object breakdown of a multi-object run.
+// Large workloads show only the slowest objects, which are the interesting ones.
+func printObjectTable(objects []ObjectSummary) {
+	rows := objects
+	title := "Per-object results:"
+	if len(objects) > maxObjectRows {
+		rows = append([]ObjectSummary(nil), objects...)
+		sort.Slice(rows, func(i, j int) bool { return rows[i].ThroughputMB < rows[j].ThroughputMB })
+		rows = rows[:maxObjectRows]
+		title = fmt.Sprintf("Per-object results (%d slowest of %d; use --json for all):", maxObjectRows, len(objects))
+	}
+
+	fmt.Printf("\n  %s\n", title)
+	fmt.Printf("    %-40s  %10s  %6s  %10s  %10s  %10s\n", "Key", "Size", "Chunks", "MB/s", "P50", "P99")
+	for _, o := range rows {
+		fmt.Printf("    %-40s  %10s  %6d  %10.1f  %10s  %10s\n",
+			truncateKey(o.Key, 40), formatBytes(o.Size), o.ChunkCount, o.ThroughputMB,
+			formatDuration(time.Duration(o.ChunkLatency.P50)), formatDuration(time.Duration(o.ChunkLatency.P99)))
+	}
+}
+
+// truncateKey shortens long keys from the left, keeping the distinctive tail.
+func truncateKey(key string, width int) string {
+	r := []rune(key)
+	if len(r) <= width {
+		return key
+	}
+	return "…" + string(r[len(r)-width+1:])
 }
 
 // printAggregateSummary prints throughput statistics across all runs for one concurrency level.
 func printAggregateSummary(summaries []RunSummary, cfg *Config) {
-	agg := computeAggregate(summaries)
+	agg := computeAggregate(summaries, cfg.Percentiles)
 	fmt.Printf("\n  Aggregate (%d runs):\n", agg.Runs)
 	fmt.Printf("    Throughput  Min:   %.1f MB/s  (%.3f GB/s)\n", agg.MinThroughputMB, agg.MinThroughputGB)
 	fmt.Printf("    Throughput  Max:   %.1f MB/s  (%.3f GB/s)\n", agg.MaxThroughputMB, agg.MaxThroughputGB)
 	fmt.Printf("    Throughput  Mean:  %.1f MB/s  (%.3f GB/s)\n", agg.MeanThroughputMB, agg.MeanThroughputGB)
+	if cfg.Mode == modeMixed {
+		fmt.Printf("    Rate        Min:   %.1f ops/s\n", agg.MinOpsPerSec)
+		fmt.Printf("    Rate        Max:   %.1f ops/s\n", agg.MaxOpsPerSec)
+		fmt.Printf("    Rate        Mean:  %.1f ops/s\n", agg.MeanOpsPerSec)
+	}
+	if agg.Latency != nil {
+		fmt.Printf("\n  Chunk latency across all %d runs:\n", agg.Runs)
+		printLatencyLines(*agg.Latency)
+	}
 }
 
-// printJSONSweeps emits all sweep results as JSON.
-func printJSONSweeps(sweeps []ConcurrencySweep) {
+// printWarmupComparison compares the warm-up with the measured runs of one
+// concurrency level, showing the cold-start penalty that the warm-up kept out
+// of the results.
+func printWarmupComparison(warmup, runs []RunSummary) {
+	unit, metric := comparisonMetric([]ConcurrencySweep{{Summaries: runs}})
+	_, warm, _ := metric(computeAggregate(warmup, nil))
+	_, measured, _ := metric(computeAggregate(runs, nil))
+	fmt.Printf("\n  Warm-up vs measured:\n")
+	fmt.Printf("    Warm-up mean:      %.1f %s  (%s, excluded)\n", warm, unit, plural(len(warmup), "run"))
+	fmt.Printf("    Measured mean:     %.1f %s  (%s)\n", measured, unit, plural(len(runs), "run"))
+	switch {
+	case measured <= 0:
+	case warm < measured:
+		fmt.Printf("    Cold-start cost:   %.1f%% below measured\n", (measured-warm)/measured*100)
+	default:
+		fmt.Printf("    Cold-start cost:   none (the warm-up was not slower)\n")
+	}
+}
+
+// plural formats a count with its noun, e.g. "1 run" or "3 runs".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// printJSON emits a result as indented JSON.
+func printJSON(v any) {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
-	if err := enc.Encode(sweeps); err != nil {
+	if err := enc.Encode(v); err != nil {
 		fmt.Fprintf(os.Stderr, "JSON encode error: %v\n", err)
 	}
 }
@@ -122,19 +388,21 @@ func printComparisonReport(sweeps []ConcurrencySweep) {
 	fmt.Printf("║              Concurrency Sweep Comparison               ║\n")
 	fmt.Printf("╚══════════════════════════════════════════════════════════╝\n\n")
 
+	unit, metric := comparisonMetric(sweeps)
+
 	// Find best mean throughput for highlighting and bar scaling.
 	bestMean := 0.0
 	bestIdx := 0
 	for i, sw := range sweeps {
-		if sw.Aggregate.MeanThroughputMB > bestMean {
-			bestMean = sw.Aggregate.MeanThroughputMB
+		if _, mean, _ := metric(sw.Aggregate); mean > bestMean {
+			bestMean = mean
 			bestIdx = i
 		}
 	}
 
 	// Table header.
 	fmt.Printf("  %-10s  %5s  %10s  %10s  %10s\n",
-		"Workers", "Runs", "Min MB/s", "Mean MB/s", "Max MB/s")
+		"Workers", "Runs", "Min "+unit, "Mean "+unit, "Max "+unit)
 	fmt.Printf("  %-10s  %5s  %10s  %10s  %10s\n",
 		"-------", "----", "--------", "---------", "--------")
 
@@ -144,17 +412,18 @@ func printComparisonReport(sweeps []ConcurrencySweep) {
 		if i == bestIdx {
 			best = " <-- best"
 		}
+		minV, meanV, maxV := metric(agg)
 		fmt.Printf("  %-10d  %5d  %10.1f  %10.1f  %10.1f%s\n",
 			sw.Concurrency, agg.Runs,
-			agg.MinThroughputMB, agg.MeanThroughputMB, agg.MaxThroughputMB,
+			minV, meanV, maxV,
 			best)
 	}
 
 	// ASCII bar chart of mean throughput.
 	const barWidth = 40
-	fmt.Printf("\n  Mean throughput (MB/s):\n\n")
+	fmt.Printf("\n  Mean throughput (%s):\n\n", unit)
 	for i, sw := range sweeps {
-		mean := sw.Aggregate.MeanThroughputMB
+		_, mean, _ := metric(sw.Aggregate)
 		bar := int(mean / bestMean * barWidth)
 		if bar < 1 {
 			bar = 1
@@ -172,12 +441,150 @@ func printComparisonReport(sweeps []ConcurrencySweep) {
 	}
 
 	best := sweeps[bestIdx]
+	if unit == "ops/s" {
+		fmt.Printf("\n  Best: %d workers → %.1f ops/s mean\n",
+			best.Concurrency,
+			best.Aggregate.MeanOpsPerSec)
+		return
+	}
 	fmt.Printf("\n  Best: %d workers → %.1f MB/s mean  (%.3f GB/s)\n",
 		best.Concurrency,
 		best.Aggregate.MeanThroughputMB,
 		best.Aggregate.MeanThroughputGB)
 }
 
+// heatShades shade matrix cells by their share of the best mean: below 25%,
+// 25–50%, 50–75%, 75–100% and the best cell itself.
+var heatShades = []rune{' ', '░', '▒', '▓', '█'}
+
+// printMatrixReport prints mean throughput for every chunk size × concurrency
+// cell as a heatmap-style table, with the best cell bracketed.
+func printMatrixReport(sweeps []ConcurrencySweep, chunkSizes []int64, concurrency []int) {
+	fmt.Printf("\n╔══════════════════════════════════════════════════════════╗\n")
+	fmt.Printf("║          Chunk Size × Concurrency Comparison            ║\n")
+	fmt.Printf("╚══════════════════════════════════════════════════════════╝\n\n")
+
+	unit, metric := comparisonMetric(sweeps)
+
+	type cell struct {
+		chunkSize int64
+		conc      int
+	}
+	means := make(map[cell]float64, len(sweeps))
+	var best ConcurrencySweep
+	bestMean := 0.0
+	for _, sw := range sweeps {
+		_, mean, _ := metric(sw.Aggregate)
+		means[cell{sw.ChunkSize, sw.Concurrency}] = mean
+		if mean > bestMean {
+			bestMean = mean
+			best = sw
+		}
+	}
+
+	const cellWidth = 12
+	fmt.Printf("  Mean throughput (%s), workers across, chunk size down:\n\n", unit)
+	fmt.Printf("  %10s │", "")
+	for _, conc := range concurrency {
+		fmt.Printf("%*d", cellWidth, conc)
+	}
+	fmt.Printf("\n  %s┼%s\n", repeatChar('─', 11), repeatChar('─', cellWidth*len(concurrency)))
+
+	for _, size := range chunkSizes {
+		fmt.Printf("  %10s │", formatBytes(size))
+		for _, conc := range concurrency {
+			mean, ok := means[cell{size, conc}]
+			if !ok {
+				fmt.Printf("%*s", cellWidth, "-")
+				continue
+			}
+			shade := heatShades[0]
+			if bestMean > 0 {
+				shade = heatShades[min(int(mean/bestMean*4), 4)]
+			}
+			if size == best.ChunkSize && conc == best.Concurrency {
+				fmt.Printf(" [%c %7.1f]", shade, mean)
+			} else {
+				fmt.Printf("  %c %7.1f ", shade, mean)
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("\n  Shading: ░ ≥25%%  ▒ ≥50%%  ▓ ≥75%% of best  █ best (bracketed)\n")
+	fmt.Printf("\n  Best: %s chunks × %d workers → %.1f %s mean",
+		formatBytes(best.ChunkSize), best.Concurrency, bestMean, unit)
+	if unit == "MB/s" {
+		fmt.Printf("  (%.3f GB/s)", best.Aggregate.MeanThroughputGB)
+	}
+	fmt.Println()
+}
+
+// tuneDisplay describes the auto-tune search for the header.
+func tuneDisplay(cfg *Config) string {
+	s := fmt.Sprintf("auto-tune from %d workers (up to %d, min gain %.1f%%", cfg.ConcurrencyList[0], cfg.TuneMaxConc, cfg.TuneMinGain)
+	if cfg.TuneMaxP99 > 0 {
+		s += ", P99 limit " + formatDuration(cfg.TuneMaxP99)
+	}
+	return s + ")"
+}
+
+// printTuneStep prints one line of the auto-tune search as it happens.
+func printTuneStep(st TuneStep, unit string) {
+	verdict := "rejected"
+	if st.Accepted {
+		verdict = "accepted"
+	}
+	fmt.Printf("  [%-6s] %5d workers  %10.1f %s  P99 %10s  %+7.1f%%  %s (%s)\n",
+		st.Phase, st.Concurrency, st.Mean, unit, formatDuration(time.Duration(st.P99)), st.GainPct, verdict, st.Reason)
+}
+
+// printTuneReport prints the search path and the knee found by --auto-tune.
+func printTuneReport(rep TuneReport) {
+	fmt.Printf("\n╔══════════════════════════════════════════════════════════╗\n")
+	fmt.Printf("║                  Auto-tune Search Path                  ║\n")
+	fmt.Printf("╚══════════════════════════════════════════════════════════╝\n\n")
+
+	fmt.Printf("  %-4s  %-6s  %8s  %12s  %10s  %8s  %s\n",
+		"Step", "Phase", "Workers", "Mean "+rep.Unit, "P99", "Gain", "Result")
+	fmt.Printf("  %-4s  %-6s  %8s  %12s  %10s  %8s  %s\n",
+		"----", "-----", "-------", "---------", "---", "----", "------")
+	for i, st := range rep.Steps {
+		verdict := "rejected"
+		if st.Accepted {
+			verdict = "accepted"
+		}
+		gain := fmt.Sprintf("%+.1f%%", st.GainPct)
+		if i == 0 {
+			gain = "-"
+		}
+		knee := ""
+		if st.Concurrency == rep.Knee && st.Accepted {
+			knee = " <-- knee"
+		}
+		fmt.Printf("  %-4d  %-6s  %8d  %12.1f  %10s  %8s  %s (%s)%s\n",
+			i+1, st.Phase, st.Concurrency, st.Mean, formatDuration(time.Duration(st.P99)), gain, verdict, st.Reason, knee)
+	}
+
+	fmt.Printf("\n  Knee: %d workers → %.1f %s mean, P99 %s\n", rep.Knee, rep.KneeMean, rep.Unit, formatDuration(time.Duration(rep.KneeP99)))
+	if rep.Note != "" {
+		fmt.Printf("  Note: %s\n", rep.Note)
+	}
+}
+
+// comparisonMetric picks the figure sweeps are ranked by: ops/s for the mixed
+// small-object workload, where bytes moved are incidental, and MB/s otherwise.
+func comparisonMetric(sweeps []ConcurrencySweep) (string, func(AggregateSummary) (min, mean, max float64)) {
+	if len(sweeps) > 0 && len(sweeps[0].Summaries) > 0 && sweeps[0].Summaries[0].Operation == modeMixed {
+		return "ops/s", func(a AggregateSummary) (float64, float64, float64) {
+			return a.MinOpsPerSec, a.MeanOpsPerSec, a.MaxOpsPerSec
+		}
+	}
+	return "MB/s", func(a AggregateSummary) (float64, float64, float64) {
+		return a.MinThroughputMB, a.MeanThroughputMB, a.MaxThroughputMB
+	}
+}
+
 func repeatChar(ch rune, n int) string {
 	if n <= 0 {
 		return ""
@@ -0,0 +1,108 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// patternBlock is the granularity of the compressibility setting.
+const patternBlock = 4096
+
+// patternReaderAt produces a deterministic, incompressible byte stream derived
+// from a seed. Any offset can be generated independently, so upload workers can
+// read their parts in parallel without holding the payload in memory.
+//
+// When random is set, only the first random bytes of every 4 KiB block come
+// from the pattern and the rest are zero, so the data compresses by roughly
+// patternBlock/random. Zero means the whole block is pattern data.
+type patternReaderAt struct {
+	seed   int64
+	size   int64
+	random int
+}
+
+// newPatternReader returns a pattern of size bytes that compresses by about
+// ratio (1 = incompressible).
+func newPatternReader(seed, size int64, ratio float64) *patternReaderAt {
+	pr := &patternReaderAt{seed: seed, size: size}
+	if ratio > 1 {
+		pr.random = max(1, int(math.Round(patternBlock/ratio)))
+	}
+	return pr
+}
+
+// ReadAt fills p with the pattern bytes starting at off.
+func (pr *patternReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= pr.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if remaining := pr.size - off; int64(n) > remaining {
+		n = int(remaining)
+	}
+
+	var word [8]byte
+	for i := 0; i < n; {
+		pos := off + int64(i)
+		end := n
+		if pr.random > 0 {
+			inBlock := int(pos % patternBlock)
+			if inBlock >= pr.random {
+				end = min(n, i+patternBlock-inBlock)
+				clear(p[i:end])
+				i = end
+				continue
+			}
+			end = min(n, i+pr.random-inBlock)
+		}
+		wordIdx := pos / 8
+		binary.LittleEndian.PutUint64(word[:], patternWord(pr.seed, wordIdx))
+		i += copy(p[i:end], word[pos%8:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// patternWord returns the 8-byte pattern word at wordIdx using splitmix64,
+// which is fast and statistically strong enough to defeat compression.
+func patternWord(seed, wordIdx int64) uint64 {
+	z := uint64(seed) + uint64(wordIdx)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// openPayload returns the data source for an upload run along with its size.
+// When --upload-file is set the file is read in place; otherwise a seeded
+// pattern of --size bytes is generated on the fly. The returned close function
+// must be called once the payload is no longer needed.
+func openPayload(cfg *Config) (io.ReaderAt, int64, func() error, error) {
+	if cfg.UploadFile == "" {
+		return newPatternReader(cfg.Seed, cfg.UploadSize, cfg.Compressibility), cfg.UploadSize, func() error { return nil }, nil
+	}
+
+	f, err := os.Open(cfg.UploadFile)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("opening upload file %q: %w", cfg.UploadFile, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, nil, fmt.Errorf("stat upload file %q: %w", cfg.UploadFile, err)
+	}
+	if info.Size() == 0 {
+		f.Close()
+		return nil, 0, nil, fmt.Errorf("upload file %q is empty", cfg.UploadFile)
+	}
+	return f, info.Size(), f.Close, nil
+}
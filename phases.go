@@ -0,0 +1,124 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// PhaseTiming breaks a single HTTP request into its connection phases.
+// Phases that did not happen (e.g. DNS and TLS on a reused connection) are zero
+// and flagged by the Did* fields so they are left out of percentiles.
+type PhaseTiming struct {
+	Overhead     time.Duration // SDK call start → connection requested (middleware, signing)
+	DNS          time.Duration
+	Connect      time.Duration // TCP connect
+	TLS          time.Duration // TLS handshake
+	WroteRequest time.Duration // connection obtained → request (headers and body) fully written
+	ServerWait   time.Duration // request written → first response byte (server think time)
+	FirstByte    time.Duration // connection requested → first response byte
+	Reused       bool          // connection came from the idle pool
+	DidDNS       bool
+	DidConnect   bool
+	DidTLS       bool
+}
+
+// phaseRecorder collects httptrace callbacks for one request. Callbacks can
+// arrive on different goroutines (DNS and dial run concurrently), so access
+// is serialised. If the SDK retries, each attempt resets the recorder so the
+// timings describe the attempt that produced the final response.
+type phaseRecorder struct {
+	mu        sync.Mutex
+	callStart time.Time
+	t         phaseStamps
+}
+
+// phaseStamps are the raw timestamps of one request attempt.
+type phaseStamps struct {
+	getConn, gotConn         time.Time
+	dnsStart, dnsDone        time.Time
+	connectStart, connectEnd time.Time
+	tlsStart, tlsDone        time.Time
+	wroteRequest, firstByte  time.Time
+	reused                   bool
+}
+
+// withPhaseTrace returns a context that records HTTP phase timings for the
+// request made with it. callStart is when the SDK call was issued.
+func withPhaseTrace(ctx context.Context, callStart time.Time) (context.Context, *phaseRecorder) {
+	rec := &phaseRecorder{callStart: callStart}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			rec.t = phaseStamps{getConn: time.Now()}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			rec.mu.Lock()
+			rec.t.gotConn = time.Now()
+			rec.t.reused = info.Reused
+			rec.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { rec.mark(&rec.t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { rec.mark(&rec.t.dnsDone) },
+		ConnectStart: func(string, string) {
+			rec.mu.Lock()
+			// Happy-eyeballs may dial several addresses; time from the first.
+			if rec.t.connectStart.IsZero() {
+				rec.t.connectStart = time.Now()
+			}
+			rec.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				rec.mark(&rec.t.connectEnd)
+			}
+		},
+		TLSHandshakeStart:    func() { rec.mark(&rec.t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { rec.mark(&rec.t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { rec.mark(&rec.t.wroteRequest) },
+		GotFirstResponseByte: func() { rec.mark(&rec.t.firstByte) },
+	}
+	return httptrace.WithClientTrace(ctx, trace), rec
+}
+
+func (r *phaseRecorder) mark(t *time.Time) {
+	now := time.Now()
+	r.mu.Lock()
+	*t = now
+	r.mu.Unlock()
+}
+
+// timings converts the recorded timestamps into phase durations.
+func (r *phaseRecorder) timings() PhaseTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	between := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+
+	p := PhaseTiming{
+		Overhead:     between(r.callStart, r.t.getConn),
+		DNS:          between(r.t.dnsStart, r.t.dnsDone),
+		Connect:      between(r.t.connectStart, r.t.connectEnd),
+		TLS:          between(r.t.tlsStart, r.t.tlsDone),
+		WroteRequest: between(r.t.gotConn, r.t.wroteRequest),
+		ServerWait:   between(r.t.wroteRequest, r.t.firstByte),
+		FirstByte:    between(r.t.getConn, r.t.firstByte),
+		Reused:       r.t.reused,
+	}
+	p.DidDNS = !r.t.dnsDone.IsZero()
+	p.DidConnect = !r.t.connectEnd.IsZero()
+	p.DidTLS = !r.t.tlsDone.IsZero()
+	return p
+}
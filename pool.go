@@ -0,0 +1,142 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runWorkerPool executes fn for chunks from the plan using a fixed-size worker
+// pool and collects the results into a DownloadResult. It is shared by the
+// download and upload paths so both are measured in exactly the same way.
+//
+// Normally every chunk is handed out exactly once. With cfg.Duration set the
+// plan is instead cycled (or sampled at random with cfg.RandomRanges) until the
+// time budget expires; requests already in flight at the deadline are allowed
+// to finish and count towards the run.
+//
+// Each chunk is retried according to cfg.Retry. Chunks that still fail are
+// kept in the result with Err set; the run is only aborted once the failures
+// exceed cfg.ErrorBudget. A percentage budget for a --duration run is checked
+// against the chunks completed, once the run has finished.
+//
+// live counts the chunks in flight and finished as the run goes.
+func runWorkerPool(cfg *Config, chunks []ChunkSpec, concurrency int, live *liveCounters, fn func(ChunkSpec) ChunkResult) (DownloadResult, error) {
+	timed := cfg.Duration > 0
+	stop := make(chan struct{})
+
+	var jobs chan ChunkSpec
+	if timed {
+		jobs = make(chan ChunkSpec)
+		go feedChunks(jobs, stop, chunks, cfg.Duration, cfg.RandomRanges)
+	} else {
+		jobs = make(chan ChunkSpec, len(chunks))
+		for _, c := range chunks {
+			jobs <- c
+		}
+		close(jobs)
+	}
+
+	results := make([]ChunkResult, 0, len(chunks))
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		firstTTFB time.Duration
+		ttfbSet   bool
+		failures  int
+		firstErr  error
+		aborted   atomic.Bool
+	)
+
+	// Percentages of an open-ended --duration run can only be judged at the end.
+	checkLive := !timed || cfg.ErrorBudget.Percent == 0
+
+	overallStart := time.Now()
+
+	workers := concurrency
+	if !timed && workers > len(chunks) {
+		workers = len(chunks)
+	}
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range jobs {
+				if aborted.Load() {
+					break
+				}
+				live.InFlight.Add(1)
+				res := runWithRetries(cfg.Retry, chunk, fn)
+				res.Worker = worker
+				live.InFlight.Add(-1)
+				live.finish(res)
+
+				mu.Lock()
+				results = append(results, res)
+				if res.Err == nil && !ttfbSet {
+					firstTTFB = res.TTFB
+					ttfbSet = true
+				}
+				if res.Err != nil {
+					failures++
+					if firstErr == nil {
+						firstErr = res.Err
+					}
+					if checkLive && cfg.ErrorBudget.exceeded(failures, len(chunks)) && !aborted.Swap(true) {
+						close(stop)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	totalTime := time.Since(overallStart)
+
+	if aborted.Load() || (!checkLive && cfg.ErrorBudget.exceeded(failures, len(results))) {
+		if cfg.ErrorBudget == (ErrorBudget{}) {
+			return DownloadResult{}, firstErr
+		}
+		return DownloadResult{}, fmt.Errorf("%d of %d chunks failed, exceeding the error budget of %s; first error: %w",
+			failures, len(results), cfg.ErrorBudget, firstErr)
+	}
+
+	return DownloadResult{
+		Chunks:    results,
+		TotalTime: totalTime,
+		TTFB:      firstTTFB,
+	}, nil
+}
+
+// feedChunks hands chunks to workers until duration has elapsed or stop is
+// closed, then closes jobs. The channel is unbuffered so no chunk is queued
+// past the deadline.
+func feedChunks(jobs chan<- ChunkSpec, stop <-chan struct{}, chunks []ChunkSpec, duration time.Duration, random bool) {
+	defer close(jobs)
+
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+
+	for i := 0; ; i++ {
+		next := chunks[i%len(chunks)]
+		if random {
+			next = chunks[rand.IntN(len(chunks))]
+		}
+		select {
+		case jobs <- next:
+		case <-deadline.C:
+			return
+		case <-stop:
+			return
+		}
+	}
+}
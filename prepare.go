@@ -0,0 +1,412 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// modePrepare is cfg.Mode for the prepare command. Like the --mode values it
+// is recorded in the metadata of every object the command writes.
+const modePrepare = "prepare"
+
+// defaultPreparePrefix is where prepare writes and cleanup looks by default.
+const defaultPreparePrefix = "s3bench-data/"
+
+// maxDeleteBatch is the most keys a single DeleteObjects request may carry.
+const maxDeleteBatch = 1000
+
+// prepareBatch is one COUNTxSIZE entry of --objects.
+type prepareBatch struct {
+	Count int
+	Size  int64
+	Label string // size as written on the command line, used in object keys
+}
+
+// parsePrepareObjects parses a list such as "100x1MB,4x1GB". A bare size
+// means one object of that size.
+func parsePrepareObjects(s string) ([]prepareBatch, error) {
+	var batches []prepareBatch
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// The count is optional, and "XL" is a size, so only a number before
+		// the first x counts as one. An upper-case X that starts a preset
+		// belongs to its name: "2XL" lacks the separator and is rejected
+		// rather than read as two L objects.
+		n, size := 1, part
+		if i := strings.IndexAny(part, "xX"); i > 0 {
+			if count, err := strconv.Atoi(strings.TrimSpace(part[:i])); err == nil {
+				rest := strings.TrimSpace(part[i:])
+				if _, preset := namedSizes[strings.ToUpper(rest)]; preset && part[i] == 'X' {
+					return nil, fmt.Errorf("%q is ambiguous; write COUNTxSIZE, e.g. %dx%s or %dx%s", part, count, rest, count, rest[1:])
+				}
+				n, size = count, part[i+1:]
+			}
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("invalid object count in %q", part)
+		}
+		bytes, err := parseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		if bytes < 1 {
+			return nil, fmt.Errorf("%q: object size must be > 0", part)
+		}
+		label := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(size), " ", ""))
+		batches = append(batches, prepareBatch{Count: n, Size: bytes, Label: label})
+	}
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("at least one COUNTxSIZE entry is required")
+	}
+	return batches, nil
+}
+
+// prepareObjects expands the batches into object keys and sizes. Keys are
+// <prefix><size>/<index>, so re-running prepare with the same arguments
+// overwrites the same objects with the same contents.
+func prepareObjects(prefix string, batches []prepareBatch) []ObjectSpec {
+	var objects []ObjectSpec
+	for _, b := range batches {
+		for i := range b.Count {
+			objects = append(objects, ObjectSpec{Key: fmt.Sprintf("%s%s/%06d", prefix, b.Label, i), Size: b.Size})
+		}
+	}
+	return objects
+}
+
+// runPrepare implements "s3bench prepare".
+func runPrepare(args []string) {
+	cfg, fs, err := parsePrepareConfig(args)
+	if err != nil {
+		usageError(fs, err)
+	}
+	if _, err := prepare(context.Background(), cfg); err != nil {
+		log.Fatalf("prepare failed: %v", err)
+	}
+}
+
+// parsePrepareConfig parses the flags of the prepare command.
+func parsePrepareConfig(args []string) (*Config, *flag.FlagSet, error) {
+	cfg := &Config{Mode: modePrepare}
+	fs := newFlagSet(modePrepare)
+	conn := registerConnectionFlags(fs, cfg)
+	var rawObjects, rawChunkSize string
+	var concurrency int
+	fs.StringVar(&cfg.Prefix, "prefix", defaultPreparePrefix, "Key prefix to create the objects under")
+	fs.StringVar(&rawObjects, "objects", "1x1GB", "Objects to create as COUNTxSIZE, comma-separated (e.g. 100x1MB,4x1GB)")
+	fs.StringVar(&rawChunkSize, "chunk-size", "64MB", "Multipart part size; objects no larger than this are sent with one PutObject")
+	fs.IntVar(&concurrency, "concurrency", 16, "Parallel uploads, shared between objects and the parts of each object")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "Seed for the object contents; object i uses seed+i")
+	fs.Float64Var(&cfg.Compressibility, "compressibility", 1, "Approximate compression ratio of the contents (1 = incompressible, 4 = compresses 4:1)")
+	if err := fs.Parse(args); err != nil {
+		return nil, fs, err
+	}
+
+	if err := conn.parse(cfg); err != nil {
+		return nil, fs, err
+	}
+	var err error
+	cfg.PrepareObjects, err = parsePrepareObjects(rawObjects)
+	if err != nil {
+		return nil, fs, fmt.Errorf("--objects: %w", err)
+	}
+	cfg.ChunkSize, err = parseByteSize(rawChunkSize)
+	if err != nil {
+		return nil, fs, fmt.Errorf("--chunk-size: %w", err)
+	}
+	switch {
+	case concurrency < 1:
+		return nil, fs, fmt.Errorf("--concurrency must be >= 1")
+	case cfg.Compressibility < 1:
+		return nil, fs, fmt.Errorf("--compressibility must be >= 1")
+	case cfg.ChunkSize < minPartSize:
+		return nil, fs, fmt.Errorf("--chunk-size must be at least %s (the S3 minimum part size)", formatBytes(minPartSize))
+	}
+	cfg.ConcurrencyList = []int{concurrency}
+	resolveTransport(&cfg.Transport, cfg.ConcurrencyList)
+	return cfg, fs, nil
+}
+
+// PrepareResult summarises a prepare run.
+type PrepareResult struct {
+	Objects      int     `json:"objects"`
+	Bytes        int64   `json:"bytes"`
+	Elapsed      millis  `json:"elapsed_ms"`
+	ThroughputMB float64 `json:"throughput_mb_s"`
+}
+
+// prepare uploads a deterministic set of test objects for later download
+// benchmarks. Each object's contents are the seeded pattern, with the seed
+// offset by the object's position in the set.
+func prepare(ctx context.Context, cfg *Config) (PrepareResult, error) {
+	if cfg.Transport.InsecureSkipVerify {
+		warnInsecureTLS()
+	}
+	client, err := buildS3Client(ctx, cfg)
+	if err != nil {
+		return PrepareResult{}, fmt.Errorf("building S3 client: %w", err)
+	}
+
+	objects := prepareObjects(cfg.Prefix, cfg.PrepareObjects)
+	total := totalObjectSize(objects)
+	concurrency := cfg.ConcurrencyList[0]
+
+	// Spread the workers over objects first; large objects with few siblings
+	// get the remainder as parallel parts.
+	objectWorkers := min(concurrency, len(objects))
+	partWorkers := max(1, concurrency/objectWorkers)
+
+	if !cfg.Quiet {
+		fmt.Printf("s3bench prepare\n")
+		fmt.Printf("  Endpoint:    %s\n", endpointDisplay(cfg))
+		fmt.Printf("  Prefix:      s3://%s/%s\n", cfg.Bucket, cfg.Prefix)
+		fmt.Printf("  Objects:     %s  (%d objects, %s)\n", formatPrepareBatches(cfg.PrepareObjects), len(objects), formatBytes(total))
+		fmt.Printf("  Contents:    %s\n", contentsDisplay(cfg))
+		fmt.Printf("  Concurrency: %d objects × %d parts\n", objectWorkers, partWorkers)
+		fmt.Println()
+	}
+
+	var live liveCounters
+	var stopProgress func()
+	if !cfg.Quiet {
+		stopProgress = startProgressReporter(total, 0, &live.Bytes, false)
+	}
+	start := time.Now()
+	err = forEachParallel(len(objects), objectWorkers, func(i int) error {
+		objCfg := *cfg
+		objCfg.Key = objects[i].Key
+		objCfg.Seed = cfg.Seed + int64(i)
+		payload := newPatternReader(objCfg.Seed, objects[i].Size, cfg.Compressibility)
+		chunks := planChunks(objects[i].Size, cfg.ChunkSize)
+		if _, err := uploadObject(ctx, client, &objCfg, chunks, payload, &live, partWorkers); err != nil {
+			return fmt.Errorf("%s: %w", objects[i].Key, err)
+		}
+		return nil
+	})
+	if stopProgress != nil {
+		stopProgress()
+	}
+	if err != nil {
+		return PrepareResult{}, err
+	}
+
+	elapsed := time.Since(start)
+	res := PrepareResult{
+		Objects:      len(objects),
+		Bytes:        total,
+		Elapsed:      millis(elapsed),
+		ThroughputMB: float64(total) / (1 << 20) / elapsed.Seconds(),
+	}
+	if !cfg.Quiet {
+		fmt.Printf("Created %d objects (%s) in %s  (%.1f MB/s)\n",
+			res.Objects, formatBytes(res.Bytes), formatDuration(time.Duration(res.Elapsed)), res.ThroughputMB)
+		fmt.Printf("Benchmark them with --prefix %s; remove them with s3bench cleanup --prefix %s\n", cfg.Prefix, cfg.Prefix)
+	}
+	return res, nil
+}
+
+// runCleanup implements "s3bench cleanup".
+func runCleanup(args []string) {
+	cfg, fs, err := parseCleanupConfig(args)
+	if err != nil {
+		usageError(fs, err)
+	}
+	if _, err := cleanup(context.Background(), cfg); err != nil {
+		log.Fatalf("cleanup failed: %v", err)
+	}
+}
+
+// parseCleanupConfig parses the flags of the cleanup command.
+func parseCleanupConfig(args []string) (*Config, *flag.FlagSet, error) {
+	cfg := &Config{}
+	fs := newFlagSet("cleanup")
+	conn := registerConnectionFlags(fs, cfg)
+	var concurrency int
+	fs.StringVar(&cfg.Prefix, "prefix", "", "Key prefix to clean up (required; e.g. "+defaultPreparePrefix+")")
+	fs.IntVar(&concurrency, "concurrency", 16, "Parallel HEAD requests used to check object metadata")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "List the objects that would be deleted without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return nil, fs, err
+	}
+
+	if err := conn.parse(cfg); err != nil {
+		return nil, fs, err
+	}
+	switch {
+	case cfg.Prefix == "":
+		return nil, fs, fmt.Errorf("--prefix is required")
+	case concurrency < 1:
+		return nil, fs, fmt.Errorf("--concurrency must be >= 1")
+	}
+	cfg.ConcurrencyList = []int{concurrency}
+	resolveTransport(&cfg.Transport, cfg.ConcurrencyList)
+	return cfg, fs, nil
+}
+
+// CleanupResult summarises a cleanup run.
+type CleanupResult struct {
+	Found   int   `json:"found"`
+	Ours    int   `json:"written_by_s3bench"`
+	Bytes   int64 `json:"bytes"`
+	Deleted int   `json:"deleted"`
+	DryRun  bool  `json:"dry_run,omitempty"`
+}
+
+// cleanup deletes the objects under a prefix that s3bench wrote itself,
+// identified by their metadata. Anything else under the prefix is left alone.
+func cleanup(ctx context.Context, cfg *Config) (CleanupResult, error) {
+	res := CleanupResult{DryRun: cfg.DryRun}
+	if cfg.Transport.InsecureSkipVerify {
+		warnInsecureTLS()
+	}
+	client, err := buildS3Client(ctx, cfg)
+	if err != nil {
+		return res, fmt.Errorf("building S3 client: %w", err)
+	}
+
+	objects, err := listPrefix(ctx, client, cfg.Bucket, cfg.Prefix)
+	if err != nil {
+		return res, fmt.Errorf("listing objects: %w", err)
+	}
+
+	// Index-keyed write — each object is checked by exactly one worker.
+	ours := make([]bool, len(objects))
+	err = forEachParallel(len(objects), cfg.ConcurrencyList[0], func(i int) error {
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(cfg.Bucket),
+			Key:    aws.String(objects[i].Key),
+		})
+		if err != nil {
+			return fmt.Errorf("HeadObject %s: %w", objects[i].Key, err)
+		}
+		_, tagged := head.Metadata[metaWriter]
+		_, seeded := head.Metadata[metaSeed] // objects from versions before metaWriter
+		ours[i] = tagged || seeded
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("checking object metadata: %w", err)
+	}
+
+	var keys []string
+	for i, obj := range objects {
+		if ours[i] {
+			keys = append(keys, obj.Key)
+			res.Bytes += obj.Size
+		}
+	}
+	res.Found, res.Ours = len(objects), len(keys)
+	if !cfg.Quiet {
+		fmt.Printf("Found %d objects under s3://%s/%s: %d written by s3bench (%s), %d others left alone\n",
+			res.Found, cfg.Bucket, cfg.Prefix, res.Ours, formatBytes(res.Bytes), res.Found-res.Ours)
+	}
+
+	if cfg.DryRun {
+		if !cfg.Quiet {
+			for _, key := range keys {
+				fmt.Printf("  would delete %s\n", key)
+			}
+		}
+		return res, nil
+	}
+	res.Deleted, err = deleteKeys(ctx, client, cfg.Bucket, keys)
+	if err != nil {
+		return res, fmt.Errorf("deleted %d of %d objects: %w", res.Deleted, len(keys), err)
+	}
+	if !cfg.Quiet {
+		fmt.Printf("Deleted %d objects\n", res.Deleted)
+	}
+	return res, nil
+}
+
+// deleteKeys removes keys with DeleteObjects in batches of up to 1000 and
+// returns how many were deleted.
+func deleteKeys(ctx context.Context, client *s3.Client, bucket string, keys []string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(keys); start += maxDeleteBatch {
+		batch := keys[start:min(start+maxDeleteBatch, len(keys))]
+		ids := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			ids[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+		resp, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("DeleteObjects: %w", err)
+		}
+		// In quiet mode only failures are listed.
+		deleted += len(batch) - len(resp.Errors)
+		if len(resp.Errors) > 0 {
+			e := resp.Errors[0]
+			return deleted, fmt.Errorf("%d objects not deleted; first: %s: %s", len(resp.Errors), aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+	}
+	return deleted, nil
+}
+
+// forEachParallel calls fn for 0..n-1 using up to workers goroutines. It stops
+// handing out work after the first error, which it returns.
+func forEachParallel(n, workers int, fn func(i int) error) error {
+	var (
+		next     atomic.Int64
+		failed   atomic.Bool
+		firstErr error
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if err := fn(i); err != nil {
+					once.Do(func() { firstErr = err })
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// formatPrepareBatches renders --objects for the header, e.g. "100 × 1 MB, 4 × 1 GB".
+func formatPrepareBatches(batches []prepareBatch) string {
+	parts := make([]string, len(batches))
+	for i, b := range batches {
+		parts[i] = fmt.Sprintf("%d × %s", b.Count, formatBytes(b.Size))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// contentsDisplay describes generated object contents for the header.
+func contentsDisplay(cfg *Config) string {
+	if cfg.Compressibility > 1 {
+		return fmt.Sprintf("generated (seed %d), compressible about %g:1", cfg.Seed, cfg.Compressibility)
+	}
+	return fmt.Sprintf("generated (seed %d), incompressible", cfg.Seed)
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promBuckets are the upper bounds, in seconds, of the TTFB and chunk time
+// histograms served on --metrics-listen.
+var promBuckets = []float64{
+	0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60,
+}
+
+// promCell holds the labels of the grid cell being run.
+type promCell struct {
+	concurrency int
+	chunkSize   int64
+}
+
+// promOp labels a cell's requests by operation, and for counts also by status.
+type promOp struct {
+	promCell
+	op     string
+	status string
+}
+
+// promHistogram is a Prometheus histogram over promBuckets.
+type promHistogram struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (h *promHistogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(promBuckets)+1)
+	}
+	v := d.Seconds()
+	i, _ := slices.BinarySearch(promBuckets, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// metricsExporter serves the progress of a benchmark in the Prometheus text
+// format. It reads the same liveCounters as the progress line: bytes and
+// in-flight requests of the running cell come straight from them, and each
+// finished chunk or operation is recorded by liveCounters.finish. Totals are
+// kept across runs and cells so counters never go backwards.
+type metricsExporter struct {
+	endpoint string
+	bucket   string
+	mode     string
+	live     *liveCounters
+
+	mu       sync.Mutex
+	cell     promCell
+	running  bool
+	bytes    map[promCell]int64 // bytes of the runs before the current one
+	requests map[promOp]int64
+	ttfb     map[promOp]*promHistogram
+	elapsed  map[promOp]*promHistogram
+}
+
+// startMetricsServer listens on cfg.MetricsListen and serves /metrics from
+// live until the returned function is called.
+func startMetricsServer(cfg *Config, live *liveCounters) (func(), error) {
+	ln, err := net.Listen("tcp", cfg.MetricsListen)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listener: %w", err)
+	}
+	m := &metricsExporter{
+		endpoint: endpointLabel(cfg.Endpoint),
+		bucket:   cfg.Bucket,
+		mode:     cfg.Mode,
+		live:     live,
+		bytes:    map[promCell]int64{},
+		requests: map[promOp]int64{},
+		ttfb:     map[promOp]*promHistogram{},
+		elapsed:  map[promOp]*promHistogram{},
+	}
+	live.metrics = m
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "warning: metrics server: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics\n", ln.Addr())
+
+	return func() {
+		live.metrics = nil
+		srv.Close()
+	}, nil
+}
+
+// startCell switches the labels to a new grid cell. The bytes of the last
+// run are added to the totals of the cell they belong to first.
+func (m *metricsExporter) startCell(concurrency int, chunkSize int64) {
+	if m.mode == modeMixed {
+		chunkSize = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.foldLocked()
+	m.cell = promCell{concurrency, chunkSize}
+	m.running = true
+}
+
+// foldBytes adds the bytes of a finished run to the totals, just before the
+// counters are reset for the next one.
+func (m *metricsExporter) foldBytes() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.foldLocked()
+}
+
+func (m *metricsExporter) foldLocked() {
+	// A retry takes back the bytes of its failed attempt, so the live count
+	// can briefly dip below zero; a counter must never go backwards.
+	if n := m.live.Bytes.Swap(0); m.running && n > 0 {
+		m.bytes[m.cell] += n
+	}
+}
+
+// observe records a finished chunk or operation: one request per attempt,
+// labelled "ok" or with the error label of the attempt, and the TTFB and
+// elapsed time of a successful one.
+func (m *metricsExporter) observe(res ChunkResult) {
+	op := res.Op
+	if op == "" {
+		op = m.mode
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, label := range res.FailedAttempts {
+		m.requests[promOp{m.cell, op, label}]++
+	}
+	if res.Err != nil {
+		return
+	}
+	m.requests[promOp{m.cell, op, "ok"}]++
+	key := promOp{promCell: m.cell, op: op}
+	for _, h := range []struct {
+		into map[promOp]*promHistogram
+		d    time.Duration
+	}{{m.ttfb, res.TTFB}, {m.elapsed, res.ElapsedTotal}} {
+		if h.into[key] == nil {
+			h.into[key] = &promHistogram{}
+		}
+		h.into[key].observe(h.d)
+	}
+}
+
+// ServeHTTP writes the metrics, in the OpenMetrics format when the scraper
+// asks for it and in the Prometheus text format otherwise.
+func (m *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	bw := bufio.NewWriter(w)
+	m.write(bw, openMetrics)
+	bw.Flush()
+}
+
+// write renders every metric family, with series in a stable order.
+func (m *metricsExporter) write(w io.Writer, openMetrics bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bytes := map[promCell]int64{}
+	for cell, n := range m.bytes {
+		bytes[cell] = n
+	}
+	if m.running {
+		bytes[m.cell] += max(0, m.live.Bytes.Load())
+	}
+
+	// OpenMetrics names a counter family without its _total suffix.
+	counter := func(name, help string) {
+		family := name
+		if openMetrics {
+			family = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", family, help, family)
+	}
+
+	counter("s3bench_bytes_total", "Bytes received or sent.")
+	for _, cell := range sortedKeys(bytes, compareCells) {
+		fmt.Fprintf(w, "s3bench_bytes_total{%s} %d\n", m.labels(cell), bytes[cell])
+	}
+
+	counter("s3bench_requests_total", "Requests by operation and status: ok, an HTTP status code or an error class.")
+	for _, k := range sortedKeys(m.requests, compareOps) {
+		fmt.Fprintf(w, "s3bench_requests_total{%s,op=\"%s\",status=\"%s\"} %d\n", m.labels(k.promCell), promEscape(k.op), promEscape(k.status), m.requests[k])
+	}
+
+	fmt.Fprintf(w, "# HELP s3bench_in_flight_requests Chunks or operations being worked on.\n# TYPE s3bench_in_flight_requests gauge\n")
+	if m.running {
+		fmt.Fprintf(w, "s3bench_in_flight_requests{%s} %d\n", m.labels(m.cell), m.live.InFlight.Load())
+	}
+
+	for _, fam := range []struct {
+		name, help string
+		series     map[promOp]*promHistogram
+	}{
+		{"s3bench_ttfb_seconds", "Time to first byte of successful requests.", m.ttfb},
+		{"s3bench_chunk_duration_seconds", "Total time of successful chunks or operations, including the body.", m.elapsed},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", fam.name, fam.help, fam.name)
+		for _, k := range sortedKeys(fam.series, compareOps) {
+			h := fam.series[k]
+			labels := fmt.Sprintf("%s,op=\"%s\"", m.labels(k.promCell), promEscape(k.op))
+			var cum uint64
+			for i, le := range promBuckets {
+				cum += h.counts[i]
+				fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", fam.name, labels, strconv.FormatFloat(le, 'g', -1, 64), cum)
+			}
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", fam.name, labels, h.count)
+			fmt.Fprintf(w, "%s_sum{%s} %s\n", fam.name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+			fmt.Fprintf(w, "%s_count{%s} %d\n", fam.name, labels, h.count)
+		}
+	}
+
+	if openMetrics {
+		fmt.Fprintf(w, "# EOF\n")
+	}
+}
+
+// labels formats the labels shared by every series of a cell.
+func (m *metricsExporter) labels(cell promCell) string {
+	return fmt.Sprintf("endpoint=\"%s\",bucket=\"%s\",concurrency=\"%d\",chunk_size=\"%d\"",
+		promEscape(m.endpoint), promEscape(m.bucket), cell.concurrency, cell.chunkSize)
+}
+
+// promLabelEscaper escapes a label value the way the exposition format
+// expects: only backslash, double quote and newline. Go's %q would also
+// escape non-ASCII and control characters, which Prometheus reads literally.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(v string) string {
+	return promLabelEscaper.Replace(v)
+}
+
+func compareCells(a, b promCell) int {
+	if c := cmp.Compare(a.chunkSize, b.chunkSize); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.concurrency, b.concurrency)
+}
+
+func compareOps(a, b promOp) int {
+	if c := compareCells(a.promCell, b.promCell); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.op, b.op); c != 0 {
+		return c
+	}
+	return strings.Compare(a.status, b.status)
+}
+
+// sortedKeys returns the keys of m in the order given by cmp.
+func sortedKeys[K comparable, V any](m map[K]V, order func(a, b K) int) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, order)
+	return keys
+}
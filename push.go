@@ -0,0 +1,284 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagName is the form a --tags key must take, so the same tags are valid as
+// InfluxDB tag keys and Prometheus label names.
+var tagName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedTags are set by s3bench on every point, so a --tags key may not
+// reuse them. "job" and "instance" are allowed: they override the
+// Pushgateway grouping key.
+var reservedTags = []string{"operation", "chunk_size", "concurrency", "endpoint", "bucket", "host"}
+
+// parseTags parses a comma-separated list of key=value pairs such as
+// "env=prod,cluster=ceph-a".
+func parseTags(s string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid tag %q (want key=value)", part)
+		}
+		if !tagName.MatchString(key) {
+			return nil, fmt.Errorf("invalid tag name %q (letters, digits and underscores, not starting with a digit)", key)
+		}
+		if slices.Contains(reservedTags, key) {
+			return nil, fmt.Errorf("tag name %q is reserved (s3bench sets %s itself)", key, strings.Join(reservedTags, ", "))
+		}
+		if _, dup := tags[key]; dup {
+			return nil, fmt.Errorf("tag %q listed twice", key)
+		}
+		tags[key] = value
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, nil
+}
+
+// pushResults sends res to every sink set in cfg with client, after the
+// results have been written locally. A sink that fails is reported as a
+// warning on warn and does not stop the others or change the exit status.
+func pushResults(ctx context.Context, client *http.Client, cfg *Config, res *Result, warn io.Writer) {
+	type sink struct {
+		name string
+		send func() error
+	}
+	var sinks []sink
+	if cfg.PushInflux != "" {
+		sinks = append(sinks, sink{"InfluxDB", func() error {
+			header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+			if token := os.Getenv("INFLUX_TOKEN"); token != "" {
+				header.Set("Authorization", "Token "+token)
+			}
+			return pushHTTP(ctx, client, http.MethodPost, cfg.PushInflux, header, influxLines(res))
+		}})
+	}
+	if cfg.PushGateway != "" {
+		sinks = append(sinks, sink{"Pushgateway", func() error {
+			header := http.Header{"Content-Type": {"text/plain; version=0.0.4"}}
+			url := pushgatewayURL(cfg.PushGateway, cfg.PushJob, res)
+			return pushHTTP(ctx, client, http.MethodPut, url, header, pushgatewayText(res))
+		}})
+	}
+	if cfg.PushWebhook != "" {
+		sinks = append(sinks, sink{"webhook", func() error {
+			body, err := json.Marshal(res)
+			if err != nil {
+				return err
+			}
+			header := http.Header{"Content-Type": {"application/json"}}
+			return pushHTTP(ctx, client, http.MethodPost, cfg.PushWebhook, header, body)
+		}})
+	}
+
+	for _, s := range sinks {
+		if err := s.send(); err != nil {
+			fmt.Fprintf(warn, "warning: pushing results to %s: %v\n", s.name, err)
+		}
+	}
+}
+
+// pushHTTP sends body and treats any status outside 2xx as a failure, quoting
+// the start of the response.
+func pushHTTP(ctx context.Context, client *http.Client, method, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sinkField is one value of a grid cell as sent to InfluxDB and the
+// Pushgateway. Names follow the JSON output; times are in milliseconds.
+type sinkField struct {
+	name    string
+	value   float64
+	integer bool
+}
+
+// sinkFields returns the values of one grid cell of a result.
+func sinkFields(sw ConcurrencySweep) []sinkField {
+	a := sw.Aggregate
+	fields := []sinkField{
+		{name: "runs", value: float64(a.Runs), integer: true},
+		{name: "mean_throughput_mb_s", value: a.MeanThroughputMB},
+		{name: "min_throughput_mb_s", value: a.MinThroughputMB},
+		{name: "max_throughput_mb_s", value: a.MaxThroughputMB},
+	}
+	if a.MeanOpsPerSec > 0 {
+		fields = append(fields,
+			sinkField{name: "mean_ops_per_s", value: a.MeanOpsPerSec},
+			sinkField{name: "min_ops_per_s", value: a.MinOpsPerSec},
+			sinkField{name: "max_ops_per_s", value: a.MaxOpsPerSec},
+		)
+	}
+
+	lat := sweepLatency(sw)
+	if len(lat.Percentiles) > 0 {
+		for _, p := range lat.Percentiles {
+			name := strings.ReplaceAll(strings.ToLower(percentileLabel(p.Percentile)), ".", "_")
+			fields = append(fields, sinkField{name: "latency_" + name + "_ms", value: durationMillis(time.Duration(p.Value))})
+		}
+	} else {
+		fields = append(fields,
+			sinkField{name: "latency_p50_ms", value: durationMillis(time.Duration(lat.P50))},
+			sinkField{name: "latency_p99_ms", value: durationMillis(time.Duration(lat.P99))},
+		)
+	}
+
+	var retries, failed int
+	for _, s := range sw.Summaries {
+		retries += s.Errors.Retries
+		failed += s.Errors.Failed
+	}
+	return append(fields,
+		sinkField{name: "mean_ttfb_ms", value: durationMillis(meanTTFB(sw.Summaries))},
+		sinkField{name: "retries", value: float64(retries), integer: true},
+		sinkField{name: "failed_chunks", value: float64(failed), integer: true},
+	)
+}
+
+// sinkLabels identifies a grid cell of a result: the operation, chunk size
+// (left out for mixed runs), concurrency and target.
+func sinkLabels(res *Result, sw ConcurrencySweep) [][2]string {
+	op := res.Command
+	labels := [][2]string{{"operation", op}}
+	if op != modeMixed {
+		labels = append(labels, [2]string{"chunk_size", strconv.FormatInt(sw.ChunkSize, 10)})
+	}
+	labels = append(labels, [2]string{"concurrency", strconv.Itoa(sw.Concurrency)})
+	if res.Target != nil {
+		labels = append(labels,
+			[2]string{"endpoint", endpointLabel(res.Target.Endpoint)},
+			[2]string{"bucket", res.Target.Bucket},
+		)
+	}
+	return labels
+}
+
+// influxEscaper escapes tag keys, tag values and field keys of the InfluxDB
+// line protocol.
+var influxEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+// influxLines renders one "s3bench" line per grid cell, tagged with the cell,
+// the client host and the --tags, and timestamped with the end of the run.
+func influxLines(res *Result) []byte {
+	var b bytes.Buffer
+	for _, sw := range res.benchmarkSweeps() {
+		b.WriteString("s3bench")
+		tags := sinkLabels(res, sw)
+		tags = append(tags, [2]string{"host", res.Host.Hostname})
+		for _, k := range slices.Sorted(maps.Keys(res.Tags)) {
+			tags = append(tags, [2]string{k, res.Tags[k]})
+		}
+		for _, t := range tags {
+			if t[1] != "" {
+				fmt.Fprintf(&b, ",%s=%s", influxEscaper.Replace(t[0]), influxEscaper.Replace(t[1]))
+			}
+		}
+		for i, f := range sinkFields(sw) {
+			sep := ","
+			if i == 0 {
+				sep = " "
+			}
+			if f.integer {
+				fmt.Fprintf(&b, "%s%s=%di", sep, f.name, int64(f.value))
+			} else {
+				fmt.Fprintf(&b, "%s%s=%s", sep, f.name, strconv.FormatFloat(f.value, 'f', -1, 64))
+			}
+		}
+		fmt.Fprintf(&b, " %d\n", res.Finished.UnixNano())
+	}
+	return b.Bytes()
+}
+
+// pushgatewayURL returns the URL of the Pushgateway group for a result: the
+// job, then instance (the client host unless a tag sets it) and the --tags.
+// A "job" tag is left out, since --push-job names the job. Values are
+// base64-encoded, which the Pushgateway accepts for any value.
+func pushgatewayURL(base, job string, res *Result) string {
+	group := map[string]string{"instance": res.Host.Hostname}
+	maps.Copy(group, res.Tags)
+	delete(group, "job")
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(base, "/"))
+	b.WriteString("/metrics/job@base64/" + base64.RawURLEncoding.EncodeToString([]byte(job)))
+	for _, k := range slices.Sorted(maps.Keys(group)) {
+		if group[k] != "" {
+			b.WriteString("/" + k + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(group[k])))
+		}
+	}
+	return b.String()
+}
+
+// pushgatewayText renders every value of every grid cell as a gauge named
+// s3bench_<field>. The --tags are part of the group, so they are not repeated
+// on each series.
+func pushgatewayText(res *Result) []byte {
+	type series struct {
+		labels string
+		value  float64
+	}
+	var names []string
+	values := map[string][]series{}
+	for _, sw := range res.benchmarkSweeps() {
+		var labels []string
+		for _, l := range sinkLabels(res, sw) {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", l[0], promEscape(l[1])))
+		}
+		for _, f := range sinkFields(sw) {
+			name := "s3bench_" + f.name
+			if _, seen := values[name]; !seen {
+				names = append(names, name)
+			}
+			values[name] = append(values[name], series{strings.Join(labels, ","), f.value})
+		}
+	}
+
+	var b bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		for _, s := range values[name] {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pushRequest is what a test sink received.
+type pushRequest struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// newSink starts a server that records every request and answers with status.
+func newSink(t *testing.T, status int) (*httptest.Server, *[]pushRequest) {
+	t.Helper()
+	var got []pushRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, pushRequest{r.Method, r.URL.EscapedPath(), r.Header.Clone(), body})
+		if status/100 != 2 {
+			http.Error(w, "sink unavailable", status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+// testResult is a one-cell download result as the sinks see it.
+func testResult() *Result {
+	return &Result{
+		Command:  modeDownload,
+		Finished: time.Unix(1700000000, 0),
+		Host:     HostInfo{Hostname: "client-1"},
+		Target:   &TargetInfo{Endpoint: "http://s3.local:9000", Bucket: "bench"},
+		Tags:     map[string]string{"env": "nightly run"},
+		Sweeps: []ConcurrencySweep{{
+			Concurrency: 8,
+			ChunkSize:   8 << 20,
+			Summaries: []RunSummary{{
+				ChunkLatency: LatencyStats{P50: millis(10 * time.Millisecond), P99: millis(25 * time.Millisecond)},
+			}},
+			Aggregate: AggregateSummary{Runs: 1, MinThroughputMB: 500, MaxThroughputMB: 500, MeanThroughputMB: 500},
+		}},
+	}
+}
+
+func TestPushInflux(t *testing.T) {
+	srv, got := newSink(t, http.StatusNoContent)
+	t.Setenv("INFLUX_TOKEN", "secret")
+	cfg := &Config{PushInflux: srv.URL + "/api/v2/write?org=o&bucket=b"}
+
+	var warn bytes.Buffer
+	pushResults(context.Background(), srv.Client(), cfg, testResult(), &warn)
+
+	if warn.Len() > 0 {
+		t.Fatalf("unexpected warning: %s", warn.String())
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	req := (*got)[0]
+	if req.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.method)
+	}
+	if auth := req.header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("Authorization = %q, want %q", auth, "Token secret")
+	}
+	line := string(req.body)
+	for _, want := range []string{
+		"s3bench,operation=download,chunk_size=8388608,concurrency=8,endpoint=http://s3.local:9000,bucket=bench,host=client-1,env=nightly\\ run ",
+		" runs=1i,mean_throughput_mb_s=500,",
+		",latency_p99_ms=25,",
+		" 1700000000000000000\n",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line protocol %q does not contain %q", line, want)
+		}
+	}
+}
+
+func TestPushGateway(t *testing.T) {
+	srv, got := newSink(t, http.StatusOK)
+	cfg := &Config{PushGateway: srv.URL + "/", PushJob: "nightly"}
+
+	var warn bytes.Buffer
+	pushResults(context.Background(), srv.Client(), cfg, testResult(), &warn)
+
+	if warn.Len() > 0 {
+		t.Fatalf("unexpected warning: %s", warn.String())
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	req := (*got)[0]
+	if req.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	wantPath := "/metrics/job@base64/" + b64("nightly") +
+		"/env@base64/" + b64("nightly run") +
+		"/instance@base64/" + b64("client-1")
+	if req.path != wantPath {
+		t.Errorf("path = %s, want %s", req.path, wantPath)
+	}
+	wantSeries := `s3bench_mean_throughput_mb_s{operation="download",chunk_size="8388608",concurrency="8",endpoint="http://s3.local:9000",bucket="bench"} 500`
+	if !strings.Contains(string(req.body), "# TYPE s3bench_mean_throughput_mb_s gauge\n"+wantSeries+"\n") {
+		t.Errorf("body does not contain %q:\n%s", wantSeries, req.body)
+	}
+}
+
+func TestPushWebhook(t *testing.T) {
+	srv, got := newSink(t, http.StatusAccepted)
+	cfg := &Config{PushWebhook: srv.URL + "/hook"}
+
+	var warn bytes.Buffer
+	pushResults(context.Background(), srv.Client(), cfg, testResult(), &warn)
+
+	if warn.Len() > 0 {
+		t.Fatalf("unexpected warning: %s", warn.String())
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*got))
+	}
+	req := (*got)[0]
+	if ct := req.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var res Result
+	if err := json.Unmarshal(req.body, &res); err != nil {
+		t.Fatalf("decoding webhook body: %v", err)
+	}
+	if res.Command != modeDownload || res.Tags["env"] != "nightly run" || len(res.Sweeps) != 1 {
+		t.Errorf("webhook result = %+v", res)
+	}
+	if p99 := res.Sweeps[0].Summaries[0].ChunkLatency.P99; time.Duration(p99) != 25*time.Millisecond {
+		t.Errorf("P99 = %v, want 25ms", time.Duration(p99))
+	}
+}
+
+func TestPushFailureIsWarning(t *testing.T) {
+	failing, _ := newSink(t, http.StatusInternalServerError)
+	ok, got := newSink(t, http.StatusOK)
+	cfg := &Config{PushInflux: failing.URL + "/write", PushWebhook: ok.URL + "/hook"}
+
+	var warn bytes.Buffer
+	pushResults(context.Background(), http.DefaultClient, cfg, testResult(), &warn)
+
+	msg := warn.String()
+	if !strings.HasPrefix(msg, "warning: pushing results to InfluxDB: ") ||
+		!strings.Contains(msg, "500 Internal Server Error: sink unavailable") {
+		t.Errorf("warning = %q", msg)
+	}
+	if len(*got) != 1 {
+		t.Errorf("webhook got %d requests after the InfluxDB failure, want 1", len(*got))
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := parseTags("env=nightly, job=ci,instance=rack-4")
+	if err != nil {
+		t.Fatalf("parseTags: %v", err)
+	}
+	if len(tags) != 3 || tags["env"] != "nightly" || tags["job"] != "ci" || tags["instance"] != "rack-4" {
+		t.Errorf("tags = %v", tags)
+	}
+
+	for _, bad := range []string{"env", "env=", "1env=x", "env=a,env=b", "host=x", "bucket=x", "concurrency=4"} {
+		if _, err := parseTags(bad); err == nil {
+			t.Errorf("parseTags(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Labels used in the error breakdown for failures without an HTTP status.
+const (
+	errNetwork   = "network"   // connection refused/reset, timeouts, truncated bodies
+	errIntegrity = "integrity" // --verify found data that does not match the object
+	errOther     = "other"     // anything else, e.g. a failed write to the output file
+)
+
+// RetryPolicy controls how a failed chunk request is retried. Measured requests
+// are retried by s3bench itself rather than by the SDK, so every attempt can be
+// counted and classified. Network errors are always retryable; HTTP errors only
+// when their status code is listed.
+type RetryPolicy struct {
+	MaxAttempts int    `json:"max_attempts"` // total attempts, including the first
+	Backoff     millis `json:"backoff_ms"`   // delay before the first retry, doubled each time
+	MaxBackoff  millis `json:"max_backoff_ms"`
+	StatusCodes []int  `json:"retry_status_codes"`
+}
+
+// noSDKRetries is passed to measured S3 calls so their only retries are the
+// ones counted by runWithRetries. Setup calls keep the SDK's default retryer.
+var noSDKRetries = func(o *s3.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+// parseStatusCodes parses a comma-separated list such as "429,500,503".
+func parseStatusCodes(s string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// classify labels a failed attempt for the error breakdown and decides whether
+// it is worth retrying.
+func (p RetryPolicy) classify(err error) (label string, retryable bool) {
+	var integrity *IntegrityError
+	if errors.As(err, &integrity) {
+		return errIntegrity, false
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		code := status.HTTPStatusCode()
+		return strconv.Itoa(code), slices.Contains(p.StatusCodes, code)
+	}
+	if errors.Is(err, context.Canceled) {
+		return errOther, false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return errNetwork, true
+	}
+	return errOther, false
+}
+
+// backoff returns the delay before retry number n (1-based): exponential
+// growth from Backoff capped at MaxBackoff, with full jitter so workers that
+// failed together do not retry in lock-step.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.Backoff) * math.Pow(2, float64(n-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+// display summarises the policy for the header.
+func (p RetryPolicy) display() string {
+	if p.MaxAttempts <= 1 {
+		return "off"
+	}
+	codes := make([]string, len(p.StatusCodes))
+	for i, c := range p.StatusCodes {
+		codes[i] = strconv.Itoa(c)
+	}
+	on := "network errors"
+	if len(codes) > 0 {
+		on = strings.Join(codes, ",") + " and network errors"
+	}
+	return fmt.Sprintf("up to %d attempts, backoff %s..%s, on %s",
+		p.MaxAttempts, formatDuration(time.Duration(p.Backoff)), formatDuration(time.Duration(p.MaxBackoff)), on)
+}
+
+// runWithRetries performs one chunk, retrying failed attempts according to the
+// policy. The returned result is that of the last attempt; its timings describe
+// that attempt alone, while Attempts and FailedAttempts record what preceded it.
+func runWithRetries(p RetryPolicy, chunk ChunkSpec, fn func(ChunkSpec) ChunkResult) ChunkResult {
+	var failed []string
+	fallback := false
+	for attempt := 1; ; attempt++ {
+		res := fn(chunk)
+		res.Attempts = attempt
+		if res.Err != nil && res.ElapsedTotal == 0 && !res.StartTime.IsZero() {
+			// Error paths return before timing the attempt; time it here so a
+			// failure keeps its length in traces and --chunks-file.
+			res.ElapsedTotal = time.Since(res.StartTime)
+		}
+		fallback = fallback || res.Fallback
+		res.Fallback = fallback
+		if res.Err == nil {
+			res.FailedAttempts = failed
+			return res
+		}
+		label, retryable := p.classify(res.Err)
+		failed = append(failed, label)
+		res.FailedAttempts = failed
+		if !retryable || attempt >= p.MaxAttempts {
+			return res
+		}
+		// A retry repeats the failed request: the same object and, in a mixed
+		// workload, the operation actually run, so a PUT keeps its key.
+		chunk.Key, chunk.Op = res.Key, res.Op
+		time.Sleep(p.backoff(attempt))
+	}
+}
+
+// ErrorBudget is how many chunks may fail (after retries) before a run is
+// aborted: either an absolute count or a percentage of the chunks in the run.
+// The zero budget aborts on the first failure.
+type ErrorBudget struct {
+	Count   int
+	Percent float64 // used instead of Count when > 0
+}
+
+// parseErrorBudget parses "5" (chunks) or "0.5%" (of the run's chunks).
+func parseErrorBudget(s string) (ErrorBudget, error) {
+	s = strings.TrimSpace(s)
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil || v < 0 || v > 100 {
+			return ErrorBudget{}, fmt.Errorf("invalid percentage %q (must be between 0%% and 100%%)", s)
+		}
+		return ErrorBudget{Percent: v}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return ErrorBudget{}, fmt.Errorf("invalid value %q (want a chunk count or a percentage such as 1%%)", s)
+	}
+	return ErrorBudget{Count: n}, nil
+}
+
+// exceeded reports whether failed chunks out of total break the budget.
+func (b ErrorBudget) exceeded(failed, total int) bool {
+	if b.Percent > 0 {
+		return float64(failed) > b.Percent/100*float64(total)
+	}
+	return failed > b.Count
+}
+
+func (b ErrorBudget) String() string {
+	if b.Percent > 0 {
+		return strconv.FormatFloat(b.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(b.Count)
+}
+
+// ErrorStats reports retries and failures for one run. StatusCounts counts every
+// failed attempt — retried or final — by HTTP status code, or by "network",
+// "integrity" or "other".
+type ErrorStats struct {
+	Retries      int            `json:"retries"`
+	Failed       int            `json:"failed_chunks"`
+	StatusCounts map[string]int `json:"status_counts,omitempty"`
+}
+
+// splitFailures separates chunks that ultimately failed from the successful
+// ones. Only successful chunks contribute to throughput and latency statistics.
+func splitFailures(result DownloadResult) (DownloadResult, ErrorStats) {
+	var stats ErrorStats
+	ok := make([]ChunkResult, 0, len(result.Chunks))
+	for _, c := range result.Chunks {
+		if c.Attempts > 1 {
+			stats.Retries += c.Attempts - 1
+		}
+		for _, label := range c.FailedAttempts {
+			if stats.StatusCounts == nil {
+				stats.StatusCounts = map[string]int{}
+			}
+			stats.StatusCounts[label]++
+		}
+		if c.Err != nil {
+			stats.Failed++
+			continue
+		}
+		ok = append(ok, c)
+	}
+	result.Chunks = ok
+	return result, stats
+}
+
+// formatStatusCounts renders a breakdown such as "503×4, network×1", most frequent first.
+func formatStatusCounts(counts map[string]int) string {
+	labels := make([]string, 0, len(counts))
+	for l := range counts {
+		labels = append(labels, l)
+	}
+	slices.SortFunc(labels, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("%s×%d", l, counts[l])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,495 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stage types of a scenario, normally run in this order. warmup and measure
+// run a benchmark command; warmup results are reported but labelled as such.
+const (
+	stagePrepare = "prepare"
+	stageWarmup  = "warmup"
+	stageMeasure = "measure"
+	stageCleanup = "cleanup"
+)
+
+// Outcome of a stage in the scenario report.
+const (
+	stageOK      = "ok"
+	stageFailed  = "failed"
+	stageSkipped = "skipped"
+)
+
+// Scenario is a multi-step benchmark plan loaded from a JSON or YAML file.
+// Endpoints and workloads are named sets of flags that stages refer to, so one
+// file can describe the same workload against several targets or several
+// workloads against one target.
+type Scenario struct {
+	Name      string                `json:"name"`
+	Endpoints map[string]FlagValues `json:"endpoints"`
+	Workloads map[string]Workload   `json:"workloads"`
+	Stages    []Stage               `json:"stages"`
+}
+
+// FlagValues maps flag names, without dashes, to values: strings, numbers or
+// booleans, exactly as they would be given on the command line. Strings may
+// refer to environment variables as ${NAME}, which is how credentials are kept
+// out of scenario files.
+type FlagValues map[string]any
+
+// Workload is a benchmark command with its flags.
+type Workload struct {
+	Command string     `json:"command"` // download (default), upload or mixed
+	Flags   FlagValues `json:"flags"`
+}
+
+// Stage is one step of a scenario. Its flags are assembled from the endpoint,
+// then the workload, then the stage's own flags and matrix, later ones taking
+// precedence, and parsed by the stage's command exactly like a command line.
+type Stage struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`     // prepare, warmup, measure or cleanup
+	Endpoint string           `json:"endpoint"` // may be omitted if the scenario has one endpoint
+	Workload string           `json:"workload"` // warmup and measure only
+	Command  string           `json:"command"`  // overrides the workload's command
+	Flags    FlagValues       `json:"flags"`
+	Matrix   map[string][]any `json:"matrix"` // sweep lists for chunk-size and concurrency
+}
+
+// stagePlan is a stage with its command line resolved and parsed.
+type stagePlan struct {
+	Stage
+	command string
+	args    []string // as written in the file, before ${NAME} expansion
+	cfg     *Config
+}
+
+// ScenarioReport collects the results of every stage of a scenario run.
+type ScenarioReport struct {
+	Scenario string        `json:"scenario"`
+	File     string        `json:"file"`
+	Started  time.Time     `json:"started"`
+	Elapsed  millis        `json:"elapsed_ms"`
+	Failed   bool          `json:"failed"`
+	Stages   []StageReport `json:"stages"`
+}
+
+// StageReport is the outcome of one stage. Exactly one of the result fields is
+// set for a stage that ran, depending on its command.
+type StageReport struct {
+	Name     string             `json:"name"`
+	Type     string             `json:"type"`
+	Command  string             `json:"command"`
+	Args     []string           `json:"args"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error,omitempty"`
+	Elapsed  millis             `json:"elapsed_ms"`
+	Target   *TargetInfo        `json:"target,omitempty"`
+	Sweeps   []ConcurrencySweep `json:"sweeps,omitempty"`
+	AutoTune *TuneReport        `json:"auto_tune,omitempty"`
+	Prepare  *PrepareResult     `json:"prepare,omitempty"`
+	Cleanup  *CleanupResult     `json:"cleanup,omitempty"`
+}
+
+// runScenario implements "s3bench run".
+func runScenario(args []string) {
+	fs := newFlagSet("run")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: s3bench run [flags] SCENARIO.json|SCENARIO.yaml\n\nRun the stages of a scenario file and report them together.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	jsonOutput := fs.Bool("json", false, "Emit the scenario report as JSON instead of text")
+	reportFile := fs.String("report", "", "Also write the JSON scenario report to this file")
+	if err := fs.Parse(args); err != nil {
+		usageError(fs, err)
+	}
+	if fs.NArg() != 1 {
+		usageError(fs, fmt.Errorf("run takes one scenario file"))
+	}
+
+	path := fs.Arg(0)
+	sc, err := loadScenario(path)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	plans, err := sc.plan(*jsonOutput)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+
+	report := executeScenario(context.Background(), sc, path, plans, *jsonOutput)
+	result := newResult("run", report.Started)
+	result.Scenario = &report
+	result.Finished = time.Now()
+
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*reportFile, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Fatalf("writing report: %v", err)
+		}
+	}
+	if *jsonOutput {
+		printJSON(result)
+	} else {
+		printScenarioReport(report)
+	}
+	if report.Failed {
+		os.Exit(1)
+	}
+}
+
+// loadScenario reads a JSON or YAML scenario file, telling them apart by
+// extension. YAML is converted to JSON first, so both are decoded the same
+// way. Unknown fields are rejected so a typo does not silently drop part of
+// the plan.
+func loadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening scenario: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var sc Scenario
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(sc.Stages) == 0 {
+		return nil, fmt.Errorf("%s: no stages", path)
+	}
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &sc, nil
+}
+
+// yamlToJSON converts a YAML document to JSON. Mapping keys must be strings,
+// as they are in JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("converting YAML: %w", err)
+	}
+	return out, nil
+}
+
+// plan resolves and parses every stage up front, so a mistake in a late stage
+// is reported before the first one runs.
+func (sc *Scenario) plan(jsonOutput bool) ([]stagePlan, error) {
+	plans := make([]stagePlan, len(sc.Stages))
+	for i, st := range sc.Stages {
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("stage %d", i+1)
+		}
+		p, err := sc.planStage(st)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i+1, st.Name, err)
+		}
+		if jsonOutput {
+			// The scenario report owns stdout; a stage's own --format csv
+			// already made it quiet.
+			p.cfg.Quiet = true
+		}
+		plans[i] = p
+	}
+	return plans, nil
+}
+
+// planStage assembles a stage's arguments and parses them with its command's
+// flag set.
+func (sc *Scenario) planStage(st Stage) (stagePlan, error) {
+	p := stagePlan{Stage: st}
+
+	var sources []FlagValues
+	endpoint := st.Endpoint
+	if endpoint == "" && len(sc.Endpoints) == 1 {
+		for name := range sc.Endpoints {
+			endpoint = name
+		}
+	}
+	if endpoint != "" {
+		values, ok := sc.Endpoints[endpoint]
+		if !ok {
+			return p, fmt.Errorf("unknown endpoint %q", endpoint)
+		}
+		sources = append(sources, values)
+	}
+
+	switch st.Type {
+	case stagePrepare, stageCleanup:
+		if st.Workload != "" || len(st.Matrix) > 0 {
+			return p, fmt.Errorf("%s stages take flags only, not a workload or matrix", st.Type)
+		}
+		if st.Command != "" && st.Command != st.Type {
+			return p, fmt.Errorf("%s stages run the %s command", st.Type, st.Type)
+		}
+		p.command = st.Type
+	case stageWarmup, stageMeasure:
+		p.command = st.Command
+		if st.Workload != "" {
+			w, ok := sc.Workloads[st.Workload]
+			if !ok {
+				return p, fmt.Errorf("unknown workload %q", st.Workload)
+			}
+			sources = append(sources, w.Flags)
+			if p.command == "" {
+				p.command = w.Command
+			}
+		}
+		if p.command == "" {
+			p.command = modeDownload
+		}
+		if p.command != modeDownload && p.command != modeUpload && p.command != modeMixed {
+			return p, fmt.Errorf("invalid command %q (must be download, upload or mixed)", p.command)
+		}
+	default:
+		return p, fmt.Errorf("invalid type %q (must be prepare, warmup, measure or cleanup)", st.Type)
+	}
+	sources = append(sources, st.Flags)
+
+	matrix := FlagValues{}
+	for name, values := range st.Matrix {
+		if name != "chunk-size" && name != "concurrency" {
+			return p, fmt.Errorf("matrix: %q cannot be swept (use chunk-size or concurrency)", name)
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			s, err := flagValue(v)
+			if err != nil {
+				return p, fmt.Errorf("matrix %s: %w", name, err)
+			}
+			parts[i] = s
+		}
+		matrix[name] = strings.Join(parts, ",")
+	}
+	sources = append(sources, matrix)
+
+	var args, expanded []string
+	for _, values := range sources {
+		a, err := flagArgs(values, false)
+		if err != nil {
+			return p, err
+		}
+		e, err := flagArgs(values, true)
+		if err != nil {
+			return p, err
+		}
+		args = append(args, a...)
+		expanded = append(expanded, e...)
+	}
+	p.args = args
+
+	var err error
+	switch p.command {
+	case modePrepare:
+		p.cfg, _, err = parsePrepareConfig(expanded)
+	case stageCleanup:
+		p.cfg, _, err = parseCleanupConfig(expanded)
+	default:
+		p.cfg, _, err = parseConfig(p.command, expanded)
+		switch {
+		case err != nil:
+		case p.cfg.Baseline != "":
+			err = fmt.Errorf("--baseline is not supported in scenario stages; compare the stage results with s3bench compare")
+		case p.cfg.Tags != nil || p.cfg.PushInflux != "" || p.cfg.PushGateway != "" || p.cfg.PushWebhook != "":
+			err = fmt.Errorf("--tags and --push-* are not supported in scenario stages; send the report with --report or --json")
+		}
+	}
+	return p, err
+}
+
+// flagArgs turns flag values into "--name=value" arguments, sorted by name
+// so the report is stable. With expand, ${NAME} references are replaced by
+// the environment variable's value.
+func flagArgs(values FlagValues, expand bool) ([]string, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		v, err := flagValue(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if expand {
+			if v, err = expandEnv(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		args = append(args, "--"+strings.TrimLeft(name, "-")+"="+v)
+	}
+	return args, nil
+}
+
+// flagValue renders one JSON value as flag text.
+func flagValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v (want a string, number, boolean or list)", v)
+	}
+}
+
+// expandEnv replaces ${NAME} and $NAME with environment variables. Unlike
+// os.ExpandEnv it fails on unset variables, so a missing credential is not
+// silently replaced by an empty string.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	out := os.Expand(s, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// executeScenario runs the stages in order. After a failure the remaining
+// stages are skipped, except cleanup stages, which still run so a failed
+// scenario does not leave its test objects behind.
+func executeScenario(ctx context.Context, sc *Scenario, path string, plans []stagePlan, quiet bool) ScenarioReport {
+	report := ScenarioReport{Scenario: sc.Name, File: path, Started: time.Now()}
+	for i, p := range plans {
+		rep := StageReport{Name: p.Name, Type: p.Type, Command: p.command, Args: p.args, Target: resultTarget(p.cfg)}
+		if report.Failed && p.Type != stageCleanup {
+			rep.Status = stageSkipped
+			report.Stages = append(report.Stages, rep)
+			continue
+		}
+		if !quiet {
+			fmt.Printf("\n━━━ Stage %d/%d: %s (%s: %s) ━━━\n\n", i+1, len(plans), p.Name, p.Type, p.command)
+		}
+
+		start := time.Now()
+		var err error
+		switch p.command {
+		case modePrepare:
+			var res PrepareResult
+			res, err = prepare(ctx, p.cfg)
+			rep.Prepare = &res
+		case stageCleanup:
+			var res CleanupResult
+			res, err = cleanup(ctx, p.cfg)
+			rep.Cleanup = &res
+		default:
+			rep.Sweeps, rep.AutoTune, err = benchmark(ctx, p.cfg)
+		}
+		rep.Elapsed = millis(time.Since(start))
+		rep.Status = stageOK
+		if err != nil {
+			rep.Status = stageFailed
+			rep.Error = err.Error()
+			report.Failed = true
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "stage %q failed: %v\n", p.Name, err)
+			}
+		}
+		report.Stages = append(report.Stages, rep)
+	}
+	report.Elapsed = millis(time.Since(report.Started))
+	return report
+}
+
+// stageHeadline summarises a stage's result in one line.
+func stageHeadline(rep StageReport) string {
+	switch {
+	case rep.Status == stageSkipped:
+		return "skipped after an earlier failure"
+	case rep.Status == stageFailed:
+		return "FAILED: " + rep.Error
+	case rep.Prepare != nil:
+		return fmt.Sprintf("created %d objects (%s) at %.1f MB/s", rep.Prepare.Objects, formatBytes(rep.Prepare.Bytes), rep.Prepare.ThroughputMB)
+	case rep.Cleanup != nil && rep.Cleanup.DryRun:
+		return fmt.Sprintf("would delete %d of %d objects", rep.Cleanup.Ours, rep.Cleanup.Found)
+	case rep.Cleanup != nil:
+		return fmt.Sprintf("deleted %d of %d objects", rep.Cleanup.Deleted, rep.Cleanup.Found)
+	case rep.AutoTune != nil:
+		return fmt.Sprintf("knee at %d workers, %.1f %s", rep.AutoTune.Knee, rep.AutoTune.KneeMean, rep.AutoTune.Unit)
+	case len(rep.Sweeps) > 0:
+		unit, metric := comparisonMetric(rep.Sweeps)
+		best, mean := rep.Sweeps[0], -1.0
+		for _, sw := range rep.Sweeps {
+			if _, m, _ := metric(sw.Aggregate); m > mean {
+				best, mean = sw, m
+			}
+		}
+		cell := fmt.Sprintf("%d workers", best.Concurrency)
+		if best.ChunkSize > 0 {
+			cell = formatBytes(best.ChunkSize) + " × " + cell
+		}
+		if len(rep.Sweeps) == 1 {
+			return fmt.Sprintf("%.1f %s mean (%s)", mean, unit, cell)
+		}
+		return fmt.Sprintf("best %.1f %s mean (%s)", mean, unit, cell)
+	}
+	return ""
+}
+
+// printScenarioReport prints one line per stage after a scenario has run.
+func printScenarioReport(report ScenarioReport) {
+	fmt.Printf("\n╔══════════════════════════════════════════════════════════╗\n")
+	fmt.Printf("║                    Scenario Summary                     ║\n")
+	fmt.Printf("╚══════════════════════════════════════════════════════════╝\n\n")
+	fmt.Printf("  %s (%s), %d stages in %s\n\n", report.Scenario, report.File, len(report.Stages), formatDuration(time.Duration(report.Elapsed)))
+
+	width := len("Stage")
+	for _, st := range report.Stages {
+		width = max(width, len(st.Name))
+	}
+	fmt.Printf("  %2s  %-*s  %-8s  %-8s  %s\n", "#", width, "Stage", "Type", "Command", "Result")
+	fmt.Printf("  %2s  %-*s  %-8s  %-8s  %s\n", "--", width, "-----", "----", "-------", "------")
+	for i, st := range report.Stages {
+		fmt.Printf("  %2d  %-*s  %-8s  %-8s  %s\n", i+1, width, st.Name, st.Type, st.Command, stageHeadline(st))
+	}
+}
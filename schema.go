@@ -0,0 +1,254 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// resultSchema identifies the layout of --json output. Fields may be added
+// within a version; removing, renaming or changing the meaning or unit of a
+// field needs a new version.
+const resultSchema = "s3bench.result/v1"
+
+// version is the s3bench release, set at build time with
+// -ldflags "-X main.version=v1.2.3". Without it the module version or VCS
+// revision recorded by the Go toolchain is used.
+var version = ""
+
+// Result is the top-level object of --json output. It records what was run,
+// where and by which build, and holds exactly one of Sweeps, AutoTune or
+// Scenario, depending on the command.
+type Result struct {
+	Schema   string            `json:"schema"`
+	Tool     ToolInfo          `json:"tool"`
+	Command  string            `json:"command"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Host     HostInfo          `json:"host"`
+	Target   *TargetInfo       `json:"target,omitempty"`
+	Settings *ResultSettings   `json:"settings,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"` // --tags
+
+	Sweeps   []ConcurrencySweep `json:"sweeps,omitempty"`
+	AutoTune *TuneReport        `json:"auto_tune,omitempty"`
+	Scenario *ScenarioReport    `json:"scenario,omitempty"`
+}
+
+// ToolInfo identifies the s3bench build that wrote a result.
+type ToolInfo struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+}
+
+// HostInfo describes the client machine.
+type HostInfo struct {
+	Hostname string `json:"hostname"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	CPUs     int    `json:"cpus"`
+}
+
+// TargetInfo is the endpoint and the objects a benchmark ran against. Object
+// counts and sizes are in each run, since a prefix can change between runs.
+type TargetInfo struct {
+	Endpoint string `json:"endpoint"` // empty for AWS S3
+	Region   string `json:"region"`
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// ResultSettings are the benchmark settings that shape the results. The
+// transport settings are recorded per sweep.
+type ResultSettings struct {
+	ChunkSizes     []int64        `json:"chunk_sizes_bytes,omitempty"` // not used by mixed runs
+	Concurrency    []int          `json:"concurrency"`                 // the starting level for --auto-tune
+	AutoTune       bool           `json:"auto_tune"`
+	Runs           int            `json:"runs"`
+	WarmupRuns     int            `json:"warmup_runs"`
+	WarmupDuration millis         `json:"warmup_duration_ms,omitempty"`
+	Duration       millis         `json:"duration_ms,omitempty"`
+	RandomRanges   bool           `json:"random_ranges,omitempty"`
+	Verify         bool           `json:"verify,omitempty"`
+	Output         string         `json:"output,omitempty"`  // "discard" or the file downloads were written to
+	Payload        string         `json:"payload,omitempty"` // "generated" or the file uploads were read from
+	Seed           int64          `json:"seed,omitempty"`
+	OpMix          map[string]int `json:"op_mix,omitempty"`
+	ObjectSize     int64          `json:"object_size_bytes,omitempty"` // size of mixed-mode PUTs
+	Ops            int            `json:"ops,omitempty"`
+	Percentiles    []float64      `json:"percentiles"`
+	Retry          RetryPolicy    `json:"retry"`
+	ErrorBudget    string         `json:"error_budget"`
+}
+
+// newResult starts the result of a command.
+func newResult(command string, started time.Time) *Result {
+	hostname, _ := os.Hostname()
+	return &Result{
+		Schema:  resultSchema,
+		Tool:    ToolInfo{Name: "s3bench", Version: toolVersion(), GoVersion: runtime.Version()},
+		Command: command,
+		Started: started,
+		Host: HostInfo{
+			Hostname: hostname,
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			CPUs:     runtime.NumCPU(),
+		},
+	}
+}
+
+// benchmarkSweeps returns the grid cells of a benchmark result, whether
+// written by a normal run or by --auto-tune.
+func (r *Result) benchmarkSweeps() []ConcurrencySweep {
+	if r.AutoTune != nil {
+		return r.AutoTune.Sweeps
+	}
+	return r.Sweeps
+}
+
+// endpointLabel names an endpoint in metrics, where AWS S3 has no URL.
+func endpointLabel(endpoint string) string {
+	if endpoint == "" {
+		return "aws"
+	}
+	return endpoint
+}
+
+// toolVersion returns version, or what the Go toolchain recorded about the build.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return "devel-" + s.Value[:min(12, len(s.Value))]
+		}
+	}
+	return "devel"
+}
+
+// benchmarkResult wraps the outcome of a download, upload or mixed benchmark.
+func benchmarkResult(cfg *Config, started time.Time, sweeps []ConcurrencySweep, tune *TuneReport) *Result {
+	res := newResult(cfg.Mode, started)
+	res.Target = resultTarget(cfg)
+	res.Settings = resultSettings(cfg)
+	res.Tags = cfg.Tags
+	res.Sweeps = sweeps
+	res.AutoTune = tune
+	res.Finished = time.Now()
+	return res
+}
+
+// resultTarget describes where cfg points.
+func resultTarget(cfg *Config) *TargetInfo {
+	return &TargetInfo{
+		Endpoint: cfg.Endpoint,
+		Region:   cfg.Region,
+		Bucket:   cfg.Bucket,
+		Key:      cfg.Key,
+		Prefix:   cfg.Prefix,
+		Manifest: cfg.Manifest,
+	}
+}
+
+// resultSettings records the settings of a benchmark configuration.
+func resultSettings(cfg *Config) *ResultSettings {
+	s := &ResultSettings{
+		Concurrency:    cfg.ConcurrencyList,
+		AutoTune:       cfg.AutoTune,
+		Runs:           cfg.Runs,
+		WarmupRuns:     cfg.WarmupRuns,
+		WarmupDuration: millis(cfg.WarmupDuration),
+		Duration:       millis(cfg.Duration),
+		Percentiles:    cfg.Percentiles,
+		Retry:          cfg.Retry,
+		ErrorBudget:    cfg.ErrorBudget.String(),
+	}
+	switch cfg.Mode {
+	case modeDownload:
+		s.ChunkSizes = cfg.ChunkSizeList
+		s.RandomRanges = cfg.RandomRanges
+		s.Verify = cfg.Verify
+		s.Output = cfg.OutputFile
+		if cfg.DiscardOutput {
+			s.Output = "discard"
+		}
+	case modeUpload:
+		s.ChunkSizes = cfg.ChunkSizeList
+		s.Payload = cfg.UploadFile
+		if cfg.UploadFile == "" {
+			s.Payload = "generated"
+			s.Seed = cfg.Seed
+		}
+	case modeMixed:
+		s.OpMix = map[string]int{}
+		for _, w := range cfg.OpMix {
+			s.OpMix[w.Op] = w.Weight
+		}
+		s.ObjectSize = cfg.ObjectSize
+		s.Ops = cfg.Ops
+		s.Seed = cfg.Seed
+	}
+	return s
+}
+
+// readResult reads a --json result file, rejecting output from before the
+// schema existed or from a schema version this build does not know.
+func readResult(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading results: %w", err)
+	}
+	var probe struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil || probe.Schema == "" {
+		return nil, fmt.Errorf("%s: not an s3bench --json result, or written by a version before %s; re-run the benchmark to compare it", path, resultSchema)
+	}
+	if probe.Schema != resultSchema {
+		return nil, fmt.Errorf("%s: result schema %q is not supported by this build, which reads %q", path, probe.Schema, resultSchema)
+	}
+	var res Result
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &res, nil
+}
+
+// millis is a duration in a result. encoding/json would write a
+// time.Duration as integer nanoseconds; millis is written as fractional
+// milliseconds (to the microsecond) instead, matching the "_ms" field names.
+type millis time.Duration
+
+func (m millis) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, durationMillis(time.Duration(m)), 'f', -1, 64), nil
+}
+
+func (m *millis) UnmarshalJSON(b []byte) error {
+	var ms float64
+	if err := json.Unmarshal(b, &ms); err != nil {
+		return err
+	}
+	*m = millis(math.Round(ms * float64(time.Millisecond)))
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chunkRow is one chunk request of a run, as written to --chunks-file and
+// --trace-file.
+type chunkRow struct {
+	Index      int
+	Worker     int
+	Key        string
+	Op         string
+	RangeStart int64
+	RangeEnd   int64
+	Size       int64
+	Start      time.Duration // from the first request of the run starting
+	TTFB       time.Duration
+	Elapsed    time.Duration
+	Attempts   int
+	Err        string
+}
+
+// chunkRows turns a run's chunk results into rows, in the order the requests
+// started. Ranges come from the chunk plan; mixed-mode operations have none.
+// Chunks without a key of their own belong to key.
+func chunkRows(result DownloadResult, chunks []ChunkSpec, key string) []chunkRow {
+	if len(result.Chunks) == 0 {
+		return nil
+	}
+	first := result.Chunks[0].StartTime
+	for _, c := range result.Chunks {
+		if c.StartTime.Before(first) {
+			first = c.StartTime
+		}
+	}
+	rows := make([]chunkRow, 0, len(result.Chunks))
+	for _, c := range result.Chunks {
+		row := chunkRow{
+			Index:    c.Index,
+			Worker:   c.Worker,
+			Key:      c.Key,
+			Op:       c.Op,
+			Size:     c.Size,
+			Start:    c.StartTime.Sub(first),
+			TTFB:     c.TTFB,
+			Elapsed:  c.ElapsedTotal,
+			Attempts: c.Attempts,
+		}
+		if row.Key == "" {
+			row.Key = key
+		}
+		if c.Op == "" && c.Index < len(chunks) {
+			row.RangeStart, row.RangeEnd = chunks[c.Index].RangeStart, chunks[c.Index].RangeEnd
+		}
+		if c.Err != nil {
+			row.Err = c.Err.Error()
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Start < rows[j].Start })
+	return rows
+}
+
+// newTableWriter returns a CSV writer, or a TSV writer when tsv is set.
+func newTableWriter(w io.Writer, tsv bool) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if tsv {
+		cw.Comma = '\t'
+	}
+	return cw
+}
+
+// durationMillis converts a duration to fractional milliseconds, to the
+// microsecond: the unit of every time in the JSON, table, trace and pushed
+// output.
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1e3
+}
+
+// formatMillis formats a duration as durationMillis does, for a table cell.
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(durationMillis(d), 'f', -1, 64)
+}
+
+// writeRunTable writes one row per run, warm-up runs included, with the
+// run's grid cell, totals, throughput, chunk latency and errors. The latency
+// columns follow the --percentiles list.
+func writeRunTable(w io.Writer, sweeps []ConcurrencySweep, percentiles []float64, tsv bool) error {
+	cw := newTableWriter(w, tsv)
+	header := []string{
+		"operation", "chunk_size_bytes", "concurrency", "run", "warmup",
+		"object_count", "object_size_bytes", "total_bytes", "chunk_count",
+		"total_time_ms", "ttfb_ms", "throughput_mb_s", "ops_per_s",
+		"latency_min_ms", "latency_mean_ms",
+	}
+	for _, p := range percentiles {
+		header = append(header, "latency_"+strings.ToLower(percentileLabel(p))+"_ms")
+	}
+	header = append(header, "latency_max_ms", "retries", "failed_chunks", "new_connections", "reused_connections")
+	cw.Write(header)
+
+	for _, sw := range sweeps {
+		for _, runs := range [][]RunSummary{sw.Warmup, sw.Summaries} {
+			for _, s := range runs {
+				row := []string{
+					s.Operation,
+					strconv.FormatInt(s.ChunkSize, 10),
+					strconv.Itoa(s.Concurrency),
+					strconv.Itoa(s.RunNumber),
+					strconv.FormatBool(s.Warmup),
+					strconv.Itoa(s.ObjectCount),
+					strconv.FormatInt(s.ObjectSize, 10),
+					strconv.FormatInt(s.TotalBytes, 10),
+					strconv.Itoa(s.ChunkCount),
+					formatMillis(time.Duration(s.TotalTime)),
+					formatMillis(time.Duration(s.TTFB)),
+					strconv.FormatFloat(s.ThroughputMB, 'f', 2, 64),
+					strconv.FormatFloat(s.OpsPerSec, 'f', 2, 64),
+					formatMillis(time.Duration(s.ChunkLatency.Min)),
+					formatMillis(time.Duration(s.ChunkLatency.Mean)),
+				}
+				// A run without successful chunks has no percentiles;
+				// its cells are left empty to keep the columns aligned.
+				for _, p := range percentiles {
+					cell := ""
+					for _, v := range s.ChunkLatency.Percentiles {
+						if v.Percentile == p {
+							cell = formatMillis(time.Duration(v.Value))
+						}
+					}
+					row = append(row, cell)
+				}
+				row = append(row,
+					formatMillis(time.Duration(s.ChunkLatency.Max)),
+					strconv.Itoa(s.Errors.Retries),
+					strconv.Itoa(s.Errors.Failed),
+					strconv.Itoa(s.Phases.NewConns),
+					strconv.Itoa(s.Phases.ReusedConns),
+				)
+				cw.Write(row)
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeChunksFile writes the chunk rows of every run in sweeps to path.
+func writeChunksFile(path string, sweeps []ConcurrencySweep, tsv bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating chunks file: %w", err)
+	}
+	defer f.Close()
+
+	cw := newTableWriter(f, tsv)
+	cw.Write([]string{"chunk_size_bytes", "concurrency", "run", "warmup", "index", "worker", "key", "op",
+		"range_start", "range_end", "size_bytes", "start_ms", "ttfb_ms", "elapsed_ms", "attempts", "error"})
+	for _, sw := range sweeps {
+		for _, runs := range [][]RunSummary{sw.Warmup, sw.Summaries} {
+			for _, s := range runs {
+				for _, c := range s.chunks {
+					rangeStart, rangeEnd := "", ""
+					if c.Op == "" {
+						rangeStart, rangeEnd = strconv.FormatInt(c.RangeStart, 10), strconv.FormatInt(c.RangeEnd, 10)
+					}
+					cw.Write([]string{
+						strconv.FormatInt(s.ChunkSize, 10),
+						strconv.Itoa(s.Concurrency),
+						strconv.Itoa(s.RunNumber),
+						strconv.FormatBool(s.Warmup),
+						strconv.Itoa(c.Index),
+						strconv.Itoa(c.Worker),
+						c.Key,
+						c.Op,
+						rangeStart,
+						rangeEnd,
+						strconv.FormatInt(c.Size, 10),
+						formatMillis(c.Start),
+						formatMillis(c.TTFB),
+						formatMillis(c.Elapsed),
+						strconv.Itoa(c.Attempts),
+						c.Err,
+					})
+				}
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing chunks file: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// liveCounters are updated by the workers while a run is in progress. The
+// progress line and the timeline sampler read them; the run's results are
+// still computed from the per-chunk results once it has finished.
+type liveCounters struct {
+	Bytes    atomic.Int64 // bytes received or sent so far
+	Requests atomic.Int64 // chunks or operations finished, including failed ones
+	InFlight atomic.Int64 // chunks or operations currently being worked on
+
+	metrics *metricsExporter // --metrics-listen endpoint, if one is running
+}
+
+// reset zeroes the counters before a run.
+func (l *liveCounters) reset() {
+	if l.metrics != nil {
+		l.metrics.foldBytes()
+	}
+	l.Bytes.Store(0)
+	l.Requests.Store(0)
+	l.InFlight.Store(0)
+}
+
+// finish counts a chunk or operation that has finished, successfully or not.
+func (l *liveCounters) finish(res ChunkResult) {
+	l.Requests.Add(1)
+	if l.metrics != nil {
+		l.metrics.observe(res)
+	}
+}
+
+// TimelineSample is one point of a run's time series. Bytes and Requests are
+// cumulative; the rates cover the interval since the previous sample.
+type TimelineSample struct {
+	Elapsed        millis  `json:"elapsed_ms"`
+	Bytes          int64   `json:"bytes"`
+	Requests       int64   `json:"requests"`
+	InFlight       int64   `json:"in_flight"`
+	ThroughputMB   float64 `json:"throughput_mb_s"`
+	RequestsPerSec float64 `json:"requests_per_s"`
+}
+
+// startTimelineSampler records a sample of live every interval until the
+// returned function is called, which takes a final sample and returns the
+// series.
+func startTimelineSampler(live *liveCounters, interval time.Duration) func() []TimelineSample {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	var samples []TimelineSample
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		var prev TimelineSample
+
+		sample := func(now time.Time) {
+			s := TimelineSample{
+				Elapsed:  millis(now.Sub(start)),
+				Bytes:    live.Bytes.Load(),
+				Requests: live.Requests.Load(),
+				InFlight: live.InFlight.Load(),
+			}
+			if secs := time.Duration(s.Elapsed - prev.Elapsed).Seconds(); secs > 0 {
+				s.ThroughputMB = float64(s.Bytes-prev.Bytes) / (1 << 20) / secs
+				s.RequestsPerSec = float64(s.Requests-prev.Requests) / secs
+			}
+			samples = append(samples, s)
+			prev = s
+		}
+
+		for {
+			select {
+			case <-done:
+				sample(time.Now())
+				return
+			case now := <-ticker.C:
+				sample(now)
+			}
+		}
+	}()
+
+	return func() []TimelineSample {
+		close(done)
+		<-stopped
+		return samples
+	}
+}
+
+// writeTimelineCSV writes the timeline of every run in sweeps to path, one
+// row per sample, with the run's grid cell and number on each row.
+func writeTimelineCSV(path string, sweeps []ConcurrencySweep) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating timeline file: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"chunk_size_bytes", "concurrency", "run", "warmup", "elapsed_ms",
+		"bytes", "requests", "in_flight", "throughput_mb_s", "requests_per_s"})
+	for _, sw := range sweeps {
+		for _, runs := range [][]RunSummary{sw.Warmup, sw.Summaries} {
+			for _, s := range runs {
+				for _, t := range s.Timeline {
+					w.Write([]string{
+						strconv.FormatInt(s.ChunkSize, 10),
+						strconv.Itoa(s.Concurrency),
+						strconv.Itoa(s.RunNumber),
+						strconv.FormatBool(s.Warmup),
+						strconv.FormatFloat(float64(t.Elapsed)/float64(time.Millisecond), 'f', 1, 64),
+						strconv.FormatInt(t.Bytes, 10),
+						strconv.FormatInt(t.Requests, 10),
+						strconv.FormatInt(t.InFlight, 10),
+						strconv.FormatFloat(t.ThroughputMB, 'f', 2, 64),
+						strconv.FormatFloat(t.RequestsPerSec, 'f', 2, 64),
+					})
+				}
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing timeline file: %w", err)
+	}
+	return f.Close()
+}
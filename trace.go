@@ -0,0 +1,207 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TraceRecord is one chunk request in a JSON lines trace. Start is measured
+// from the first request of the run starting.
+type TraceRecord struct {
+	ChunkSize   int64   `json:"chunk_size_bytes"`
+	Concurrency int     `json:"concurrency"`
+	Run         int     `json:"run"`
+	Warmup      bool    `json:"warmup"`
+	Worker      int     `json:"worker"`
+	Index       int     `json:"index"`
+	Key         string  `json:"key"`
+	Op          string  `json:"op,omitempty"`
+	RangeStart  *int64  `json:"range_start,omitempty"`
+	RangeEnd    *int64  `json:"range_end,omitempty"`
+	Size        int64   `json:"size_bytes"`
+	Start       float64 `json:"start_ms"`
+	TTFB        float64 `json:"ttfb_ms"`
+	Elapsed     float64 `json:"elapsed_ms"`
+	Attempts    int     `json:"attempts"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// traceEvent is an event of the Chrome trace event format. Times are in
+// microseconds.
+type traceEvent struct {
+	Name string         `json:"name"`
+	Cat  string         `json:"cat,omitempty"`
+	Ph   string         `json:"ph"`
+	Ts   float64        `json:"ts"`
+	Dur  float64        `json:"dur,omitempty"`
+	Pid  int            `json:"pid"`
+	Tid  int            `json:"tid"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// writeTraceFile writes the chunk requests of every run in sweeps to path,
+// either as JSON lines or in the Chrome trace event format.
+func writeTraceFile(path, format string, sweeps []ConcurrencySweep) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating trace file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if format == traceChrome {
+		err = writeChromeTrace(w, sweeps)
+	} else {
+		err = writeTraceJSONL(w, sweeps)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("writing trace file: %w", err)
+	}
+	return f.Close()
+}
+
+// writeTraceJSONL writes one TraceRecord per line.
+func writeTraceJSONL(w *bufio.Writer, sweeps []ConcurrencySweep) error {
+	enc := json.NewEncoder(w)
+	for _, sw := range sweeps {
+		for _, runs := range [][]RunSummary{sw.Warmup, sw.Summaries} {
+			for _, s := range runs {
+				for _, c := range s.chunks {
+					rec := TraceRecord{
+						ChunkSize:   s.ChunkSize,
+						Concurrency: s.Concurrency,
+						Run:         s.RunNumber,
+						Warmup:      s.Warmup,
+						Worker:      c.Worker,
+						Index:       c.Index,
+						Key:         c.Key,
+						Op:          c.Op,
+						Size:        c.Size,
+						Start:       durationMillis(c.Start),
+						TTFB:        durationMillis(c.TTFB),
+						Elapsed:     durationMillis(c.Elapsed),
+						Attempts:    c.Attempts,
+						Error:       c.Err,
+					}
+					if c.Op == "" {
+						rec.RangeStart, rec.RangeEnd = &c.RangeStart, &c.RangeEnd
+					}
+					if err := enc.Encode(rec); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeChromeTrace writes a trace that Perfetto or chrome://tracing can load.
+// Each run is a process and each worker a thread, so gaps on a thread are
+// time the worker sat idle. Every chunk is a slice with a nested "ttfb" slice
+// covering the wait for the response headers.
+func writeChromeTrace(w *bufio.Writer, sweeps []ConcurrencySweep) error {
+	if _, err := w.WriteString("{\"displayTimeUnit\":\"ms\",\"traceEvents\":[\n"); err != nil {
+		return err
+	}
+	first := true
+	emit := func(ev traceEvent) error {
+		if !first {
+			if _, err := w.WriteString(",\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		b, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+
+	pid := 0
+	for _, sw := range sweeps {
+		for _, runs := range [][]RunSummary{sw.Warmup, sw.Summaries} {
+			for _, s := range runs {
+				pid++
+				meta := []traceEvent{
+					{Name: "process_name", Ph: "M", Pid: pid, Args: map[string]any{"name": traceRunName(s)}},
+					{Name: "process_sort_index", Ph: "M", Pid: pid, Args: map[string]any{"sort_index": pid}},
+				}
+				workers := map[int]bool{}
+				for _, c := range s.chunks {
+					if !workers[c.Worker] {
+						workers[c.Worker] = true
+						meta = append(meta, traceEvent{Name: "thread_name", Ph: "M", Pid: pid, Tid: c.Worker,
+							Args: map[string]any{"name": fmt.Sprintf("worker %d", c.Worker)}})
+					}
+				}
+				for _, ev := range meta {
+					if err := emit(ev); err != nil {
+						return err
+					}
+				}
+
+				for _, c := range s.chunks {
+					args := map[string]any{
+						"index":      c.Index,
+						"key":        c.Key,
+						"size_bytes": c.Size,
+						"ttfb_ms":    durationMillis(c.TTFB),
+						"attempts":   c.Attempts,
+					}
+					name, cat := fmt.Sprintf("chunk %d", c.Index), "chunk"
+					if c.Op != "" {
+						name, cat = c.Op, c.Op
+					} else {
+						args["range"] = fmt.Sprintf("bytes=%d-%d", c.RangeStart, c.RangeEnd)
+					}
+					if c.Err != "" {
+						name += " (failed)"
+						args["error"] = c.Err
+					}
+					start := traceMicros(c.Start)
+					if err := emit(traceEvent{Name: name, Cat: cat, Ph: "X", Ts: start, Dur: traceMicros(c.Elapsed),
+						Pid: pid, Tid: c.Worker, Args: args}); err != nil {
+						return err
+					}
+					if c.TTFB > 0 {
+						if err := emit(traceEvent{Name: "ttfb", Cat: "ttfb", Ph: "X", Ts: start, Dur: traceMicros(c.TTFB),
+							Pid: pid, Tid: c.Worker}); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	_, err := w.WriteString("\n]}\n")
+	return err
+}
+
+// traceRunName labels a run's process in the Chrome trace.
+func traceRunName(s RunSummary) string {
+	name := fmt.Sprintf("%s concurrency %d run %d", s.Operation, s.Concurrency, s.RunNumber)
+	if s.Operation != modeMixed {
+		name = fmt.Sprintf("%s chunk %s concurrency %d run %d", s.Operation, formatBytes(s.ChunkSize), s.Concurrency, s.RunNumber)
+	}
+	if s.Warmup {
+		name += " (warm-up)"
+	}
+	return name
+}
+
+// traceMicros converts a duration to the microseconds of the Chrome trace
+// event format.
+func traceMicros(d time.Duration) float64 { return float64(d.Microseconds()) }
@@ -0,0 +1,242 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// HTTP/2 modes selectable with --http2.
+const (
+	http2Auto  = "auto"  // negotiate HTTP/2 via ALPN when the server offers it
+	http2Force = "force" // fail any request that is not served over HTTP/2
+	http2Off   = "off"   // HTTP/1.1 only
+)
+
+// TransportSettings holds the HTTP transport tuning knobs. Zero values for the
+// idle pool sizes mean "size automatically from --concurrency"; resolveTransport
+// fills them in so the effective values can be echoed in the results.
+type TransportSettings struct {
+	MaxConnsPerHost       int    `json:"max_conns_per_host"` // 0 = unlimited
+	MaxIdleConns          int    `json:"max_idle_conns"`
+	MaxIdleConnsPerHost   int    `json:"max_idle_conns_per_host"`
+	IdleConnTimeout       millis `json:"idle_conn_timeout_ms"`
+	DialTimeout           millis `json:"dial_timeout_ms"`
+	ResponseHeaderTimeout millis `json:"response_header_timeout_ms"` // 0 = no limit
+	DisableKeepAlives     bool   `json:"disable_keep_alives"`
+	HTTP2                 string `json:"http2"`
+	ReadBufferSize        int    `json:"read_buffer_size_bytes"`  // 0 = Go default (4 KB)
+	WriteBufferSize       int    `json:"write_buffer_size_bytes"` // 0 = Go default (4 KB)
+	CABundle              string `json:"ca_bundle,omitempty"`
+	ClientCert            string `json:"client_cert,omitempty"`
+	ClientKey             string `json:"client_key,omitempty"`
+	TLSMinVersion         string `json:"tls_min_version"`
+	InsecureSkipVerify    bool   `json:"insecure_skip_verify"`
+}
+
+// tlsVersions maps --tls-min-version values to crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// resolveTransport fills in automatic values. The SDK's default transport keeps
+// only 10 idle connections per host, so with more workers than that connections
+// are constantly closed and re-opened; by default the pool is sized to the
+// highest concurrency level instead.
+func resolveTransport(t *TransportSettings, concurrencyList []int) {
+	maxConc := slices.Max(concurrencyList)
+	if t.MaxIdleConnsPerHost == 0 {
+		t.MaxIdleConnsPerHost = max(maxConc, awshttp.DefaultHTTPTransportMaxIdleConnsPerHost)
+	}
+	if t.MaxIdleConns == 0 {
+		t.MaxIdleConns = max(t.MaxIdleConnsPerHost, awshttp.DefaultHTTPTransportMaxIdleConns)
+	}
+}
+
+// validateTransport checks transport settings that can be checked without a connection.
+func validateTransport(t *TransportSettings, endpoint string) error {
+	switch t.HTTP2 {
+	case http2Auto, http2Off:
+	case http2Force:
+		// Go only speaks HTTP/2 over TLS (no h2c), so a plain-HTTP endpoint can never satisfy it.
+		if strings.HasPrefix(strings.ToLower(endpoint), "http://") {
+			return fmt.Errorf("--http2 force requires an https:// endpoint")
+		}
+	default:
+		return fmt.Errorf("--http2: invalid value %q (must be auto, force or off)", t.HTTP2)
+	}
+	if t.MaxConnsPerHost < 0 || t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("connection pool sizes must be >= 0")
+	}
+	if t.IdleConnTimeout < 0 || t.DialTimeout < 0 || t.ResponseHeaderTimeout < 0 {
+		return fmt.Errorf("transport timeouts must be >= 0")
+	}
+	if _, ok := tlsVersions[t.TLSMinVersion]; !ok {
+		return fmt.Errorf("--tls-min-version: invalid value %q (must be 1.0, 1.1, 1.2 or 1.3)", t.TLSMinVersion)
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return fmt.Errorf("--client-cert and --client-key must be given together")
+	}
+	return nil
+}
+
+// buildTLSConfig creates the client TLS configuration: the system trust store
+// plus any private CA bundle, an optional client certificate for mTLS, and the
+// minimum protocol version.
+func buildTLSConfig(t TransportSettings) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tlsVersions[t.TLSMinVersion],
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CABundle != "" {
+		pem, err := os.ReadFile(t.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		// Extend rather than replace the system roots so public endpoints
+		// (e.g. STS for credentials) keep working alongside the private CA.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %q contains no PEM certificates", t.CABundle)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if t.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// warnInsecureTLS prints a hard-to-miss warning when certificate verification
+// is disabled. It goes to stderr so it also appears alongside --json output.
+func warnInsecureTLS() {
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!\n")
+	fmt.Fprintf(os.Stderr, "!!  WARNING: TLS certificate verification is DISABLED                  !!\n")
+	fmt.Fprintf(os.Stderr, "!!  (--insecure-skip-verify). Connections can be intercepted and the   !!\n")
+	fmt.Fprintf(os.Stderr, "!!  server's identity is not checked. Use --ca-bundle for private CAs. !!\n")
+	fmt.Fprintf(os.Stderr, "!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!\n\n")
+}
+
+// buildHTTPClient creates the SDK HTTP client from the transport settings.
+// It stays a *BuildableClient so the SDK can still layer AWS_CA_BUNDLE on top;
+// forced HTTP/2 is enforced separately by wrapping the S3 client's HTTP client
+// with http2OnlyClient.
+func buildHTTPClient(t TransportSettings) (*awshttp.BuildableClient, error) {
+	tlsCfg, err := buildTLSConfig(t)
+	if err != nil {
+		return nil, err
+	}
+
+	client := awshttp.NewBuildableClient().
+		WithTransportOptions(func(tr *http.Transport) {
+			tr.MaxConnsPerHost = t.MaxConnsPerHost
+			tr.MaxIdleConns = t.MaxIdleConns
+			tr.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+			tr.IdleConnTimeout = time.Duration(t.IdleConnTimeout)
+			tr.ResponseHeaderTimeout = time.Duration(t.ResponseHeaderTimeout)
+			tr.DisableKeepAlives = t.DisableKeepAlives
+			tr.ReadBufferSize = t.ReadBufferSize
+			tr.WriteBufferSize = t.WriteBufferSize
+			tr.TLSClientConfig = tlsCfg
+			if t.HTTP2 == http2Off {
+				// A non-nil, empty TLSNextProto map disables HTTP/2 negotiation.
+				tr.ForceAttemptHTTP2 = false
+				tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+			}
+		}).
+		WithDialerOptions(func(d *net.Dialer) {
+			d.Timeout = time.Duration(t.DialTimeout)
+		})
+
+	return client, nil
+}
+
+// http2OnlyClient rejects any response that was not served over HTTP/2, so a
+// forced-HTTP/2 benchmark can never silently fall back to HTTP/1.1.
+type http2OnlyClient struct {
+	inner aws.HTTPClient
+}
+
+func (c *http2OnlyClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.inner.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("--http2 force: server responded with %s", resp.Proto)
+	}
+	return resp, nil
+}
+
+// transportDisplay summarises the effective transport settings for the header.
+func transportDisplay(t TransportSettings) []string {
+	maxConns := "unlimited"
+	if t.MaxConnsPerHost > 0 {
+		maxConns = fmt.Sprintf("%d", t.MaxConnsPerHost)
+	}
+	keepAlive := "on"
+	if t.DisableKeepAlives {
+		keepAlive = "off"
+	}
+	headerTimeout := "none"
+	if t.ResponseHeaderTimeout > 0 {
+		headerTimeout = formatDuration(time.Duration(t.ResponseHeaderTimeout))
+	}
+	bufSize := func(n int) string {
+		if n == 0 {
+			return "default"
+		}
+		return formatBytes(int64(n))
+	}
+	return []string{
+		fmt.Sprintf("max conns/host %s, idle pool %d (%d/host), idle timeout %s",
+			maxConns, t.MaxIdleConns, t.MaxIdleConnsPerHost, formatDuration(time.Duration(t.IdleConnTimeout))),
+		fmt.Sprintf("HTTP/2 %s, keep-alive %s, dial timeout %s, response header timeout %s",
+			t.HTTP2, keepAlive, formatDuration(time.Duration(t.DialTimeout)), headerTimeout),
+		fmt.Sprintf("read buffer %s, write buffer %s", bufSize(t.ReadBufferSize), bufSize(t.WriteBufferSize)),
+		tlsDisplay(t),
+	}
+}
+
+// tlsDisplay summarises the TLS settings for the header.
+func tlsDisplay(t TransportSettings) string {
+	parts := []string{"TLS >= " + t.TLSMinVersion}
+	if t.CABundle != "" {
+		parts = append(parts, "CA bundle "+t.CABundle)
+	}
+	if t.ClientCert != "" {
+		parts = append(parts, "client cert "+t.ClientCert)
+	}
+	if t.InsecureSkipVerify {
+		parts = append(parts, "VERIFICATION DISABLED")
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import "fmt"
+
+// Search phases of the auto-tuner.
+const (
+	tuneRamp   = "ramp"   // doubling concurrency from the starting point
+	tuneSearch = "search" // bisecting between the last good and first bad level
+)
+
+// TuneStep is one concurrency level measured by the auto-tuner.
+type TuneStep struct {
+	Phase       string  `json:"phase"`
+	Concurrency int     `json:"concurrency"`
+	Mean        float64 `json:"mean"` // mean MB/s, or ops/s in mixed mode
+	P99         millis  `json:"p99_ms"`
+	GainPct     float64 `json:"gain_pct"` // over the best level accepted so far
+	Accepted    bool    `json:"accepted"`
+	Reason      string  `json:"reason"`
+}
+
+// TuneReport is the outcome of an --auto-tune run: the knee point, the path
+// taken to find it, and the full results of every level measured.
+type TuneReport struct {
+	Unit       string             `json:"unit"`
+	MinGainPct float64            `json:"min_gain_pct"`
+	MaxP99     millis             `json:"max_p99_ms,omitempty"`
+	Knee       int                `json:"knee_concurrency"`
+	KneeMean   float64            `json:"knee_mean"`
+	KneeP99    millis             `json:"knee_p99_ms"`
+	Note       string             `json:"note,omitempty"`
+	Steps      []TuneStep         `json:"steps"`
+	Sweeps     []ConcurrencySweep `json:"sweeps"`
+}
+
+// autoTune searches for the knee of the throughput curve. Starting from the
+// --concurrency value it doubles the worker count while each doubling adds at
+// least cfg.TuneMinGain percent and P99 chunk latency stays within
+// cfg.TuneMaxP99. It then bisects between the last accepted level and the
+// first rejected one, under the same rule, until the gap is within about 6%.
+// measure runs the benchmark at one concurrency level; limit caps the search.
+// The search stops at the first level that fails to run.
+func autoTune(cfg *Config, limit int, measure func(conc int) (ConcurrencySweep, error)) (TuneReport, error) {
+	rep := TuneReport{MinGainPct: cfg.TuneMinGain, MaxP99: millis(cfg.TuneMaxP99)}
+	var metric func(AggregateSummary) (float64, float64, float64)
+
+	// try measures conc and decides whether it beats the accepted level base.
+	try := func(phase string, conc int, base *TuneStep) (TuneStep, error) {
+		sw, err := measure(conc)
+		if err != nil {
+			return TuneStep{}, err
+		}
+		rep.Sweeps = append(rep.Sweeps, sw)
+		if metric == nil {
+			rep.Unit, metric = comparisonMetric(rep.Sweeps)
+		}
+		_, mean, _ := metric(sw.Aggregate)
+		step := TuneStep{Phase: phase, Concurrency: conc, Mean: mean, P99: worstP99(sw.Summaries)}
+		if base != nil && base.Mean > 0 {
+			step.GainPct = (mean - base.Mean) / base.Mean * 100
+		}
+
+		switch {
+		case rep.MaxP99 > 0 && step.P99 > rep.MaxP99:
+			step.Reason = fmt.Sprintf("P99 over %s", formatDuration(cfg.TuneMaxP99))
+		case base == nil:
+			step.Accepted = true
+			step.Reason = "starting point"
+		case step.GainPct >= cfg.TuneMinGain:
+			step.Accepted = true
+			step.Reason = fmt.Sprintf("gain ≥ %.1f%%", cfg.TuneMinGain)
+		default:
+			step.Reason = fmt.Sprintf("gain < %.1f%%", cfg.TuneMinGain)
+		}
+
+		rep.Steps = append(rep.Steps, step)
+		if !cfg.Quiet {
+			printTuneStep(step, rep.Unit)
+		}
+		return step, nil
+	}
+
+	best, err := try(tuneRamp, cfg.ConcurrencyList[0], nil)
+	if err != nil {
+		return rep, err
+	}
+	if !best.Accepted {
+		// Keep it as the knee anyway; rep.Steps holds its own copy.
+		best.Accepted = true
+		rep.Steps[0].Accepted = true
+		rep.Note = "the starting concurrency already exceeds the P99 limit; try a lower --concurrency"
+	} else {
+		rejected := 0
+		for best.Concurrency < limit {
+			step, err := try(tuneRamp, min(best.Concurrency*2, limit), &best)
+			if err != nil {
+				return rep, err
+			}
+			if !step.Accepted {
+				rejected = step.Concurrency
+				break
+			}
+			best = step
+		}
+		if best.Concurrency >= limit {
+			rep.Note = fmt.Sprintf("stopped at the search limit of %d workers", limit)
+		}
+		for rejected > 0 && rejected-best.Concurrency > max(1, best.Concurrency/16) {
+			step, err := try(tuneSearch, (best.Concurrency+rejected)/2, &best)
+			if err != nil {
+				return rep, err
+			}
+			if step.Accepted {
+				best = step
+			} else {
+				rejected = step.Concurrency
+			}
+		}
+	}
+
+	rep.Knee = best.Concurrency
+	rep.KneeMean = best.Mean
+	rep.KneeP99 = best.P99
+	return rep, nil
+}
+
+// worstP99 returns the highest P99 chunk latency across runs, so a level only
+// passes the latency limit if every run did.
+func worstP99(summaries []RunSummary) millis {
+	var worst millis
+	for _, s := range summaries {
+		worst = max(worst, s.ChunkLatency.P99)
+	}
+	return worst
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 multipart upload limits.
+const (
+	minPartSize = 5 << 20
+	maxParts    = 10000
+)
+
+// Metadata keys s3bench attaches to objects it writes itself. metaWriter marks
+// an object as s3bench's own (the value is the command that wrote it), which
+// is what cleanup looks for before deleting anything.
+const (
+	metaWriter   = "s3bench"
+	metaSeed     = "s3bench-seed"
+	metaCompress = "s3bench-compressibility"
+)
+
+// uploadObject uploads the payload using the same chunk plan and worker pool as
+// downloads. A single-chunk plan is sent as one PutObject; anything larger is
+// sent as a multipart upload with one part per chunk.
+// live is updated as each part completes.
+func uploadObject(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	chunks []ChunkSpec,
+	payload io.ReaderAt,
+	live *liveCounters,
+	concurrency int,
+) (DownloadResult, error) {
+
+	if len(chunks) == 1 {
+		start := time.Now()
+		live.InFlight.Add(1)
+		res := runWithRetries(cfg.Retry, chunks[0], func(chunk ChunkSpec) ChunkResult {
+			return putObject(ctx, client, cfg, chunk, payload, &live.Bytes)
+		})
+		live.InFlight.Add(-1)
+		live.finish(res)
+		if res.Err != nil {
+			return DownloadResult{}, res.Err
+		}
+		return DownloadResult{
+			Chunks:    []ChunkResult{res},
+			TotalTime: time.Since(start),
+			TTFB:      res.TTFB,
+		}, nil
+	}
+
+	if len(chunks) > maxParts {
+		return DownloadResult{}, fmt.Errorf("upload needs %d parts, S3 allows at most %d — increase --chunk-size", len(chunks), maxParts)
+	}
+	if chunks[0].Size < minPartSize {
+		return DownloadResult{}, fmt.Errorf("multipart parts must be at least %s — increase --chunk-size", formatBytes(minPartSize))
+	}
+
+	start := time.Now()
+
+	created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(cfg.Bucket),
+		Key:      aws.String(cfg.Key),
+		Metadata: uploadMetadata(cfg),
+	})
+	if err != nil {
+		return DownloadResult{}, fmt.Errorf("CreateMultipartUpload: %w", err)
+	}
+	uploadID := created.UploadId
+
+	// Index-keyed write — no lock needed; each part is uploaded by exactly one worker.
+	etags := make([]*string, len(chunks))
+
+	result, err := runWorkerPool(cfg, chunks, concurrency, live, func(chunk ChunkSpec) ChunkResult {
+		res, etag := uploadPart(ctx, client, cfg, uploadID, chunk, payload, &live.Bytes)
+		etags[chunk.Index] = etag
+		return res
+	})
+	if err != nil {
+		abortUpload(client, cfg, uploadID)
+		return DownloadResult{}, err
+	}
+	// An error budget lets a run carry on past failed chunks, but a multipart
+	// upload cannot be completed with a part missing.
+	for _, c := range result.Chunks {
+		if c.Err != nil {
+			abortUpload(client, cfg, uploadID)
+			return DownloadResult{}, c.Err
+		}
+	}
+
+	parts := make([]types.CompletedPart, len(chunks))
+	for i, c := range chunks {
+		parts[i] = types.CompletedPart{
+			ETag:       etags[i],
+			PartNumber: aws.Int32(int32(c.Index + 1)),
+		}
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(cfg.Bucket),
+		Key:             aws.String(cfg.Key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abortUpload(client, cfg, uploadID)
+		return DownloadResult{}, fmt.Errorf("CompleteMultipartUpload: %w", err)
+	}
+
+	// Total time covers create, all parts and complete — the full cost of the write.
+	result.TotalTime = time.Since(start)
+	return result, nil
+}
+
+// uploadPart sends one chunk of the payload as a multipart part and records timing.
+func uploadPart(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	uploadID *string,
+	chunk ChunkSpec,
+	payload io.ReaderAt,
+	progress *atomic.Int64,
+) (ChunkResult, *string) {
+
+	start := time.Now()
+	ctx, rec := withPhaseTrace(ctx, start)
+
+	resp, err := client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(cfg.Bucket),
+		Key:           aws.String(cfg.Key),
+		UploadId:      uploadID,
+		PartNumber:    aws.Int32(int32(chunk.Index + 1)),
+		ContentLength: aws.Int64(chunk.Size),
+		Body:          io.NewSectionReader(payload, chunk.RangeStart, chunk.Size),
+	}, noSDKRetries)
+	if err != nil {
+		return ChunkResult{
+			Index:     chunk.Index,
+			StartTime: start,
+			Err:       fmt.Errorf("UploadPart %d (%s at offset %d): %w", chunk.Index+1, formatBytes(chunk.Size), chunk.RangeStart, err),
+		}, nil
+	}
+
+	// The SDK may read the body more than once (signing, checksums), so progress
+	// is only credited once the part has been accepted.
+	if progress != nil {
+		progress.Add(chunk.Size)
+	}
+
+	// For uploads the response arrives after the whole body is sent, so TTFB and
+	// total elapsed time are the same measurement.
+	elapsed := time.Since(start)
+	return ChunkResult{
+		Index:        chunk.Index,
+		Size:         chunk.Size,
+		StartTime:    start,
+		TTFB:         elapsed,
+		ElapsedTotal: elapsed,
+		Phases:       rec.timings(),
+	}, resp.ETag
+}
+
+// putObject uploads a payload that fits in a single chunk with one PutObject.
+func putObject(
+	ctx context.Context,
+	client *s3.Client,
+	cfg *Config,
+	chunk ChunkSpec,
+	payload io.ReaderAt,
+	progress *atomic.Int64,
+) ChunkResult {
+
+	start := time.Now()
+	ctx, rec := withPhaseTrace(ctx, start)
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(cfg.Bucket),
+		Key:           aws.String(cfg.Key),
+		ContentLength: aws.Int64(chunk.Size),
+		Body:          io.NewSectionReader(payload, chunk.RangeStart, chunk.Size),
+		Metadata:      uploadMetadata(cfg),
+	}, noSDKRetries)
+	if err != nil {
+		return ChunkResult{
+			Index:     chunk.Index,
+			StartTime: start,
+			Err:       fmt.Errorf("PutObject (%s): %w", formatBytes(chunk.Size), err),
+		}
+	}
+
+	if progress != nil {
+		progress.Add(chunk.Size)
+	}
+
+	elapsed := time.Since(start)
+	return ChunkResult{
+		Index:        chunk.Index,
+		Size:         chunk.Size,
+		StartTime:    start,
+		TTFB:         elapsed,
+		ElapsedTotal: elapsed,
+		Phases:       rec.timings(),
+	}
+}
+
+// abortUpload makes a best-effort attempt to discard the parts of a failed
+// multipart upload so they do not accumulate storage charges.
+func abortUpload(client *s3.Client, cfg *Config, uploadID *string) {
+	// Use a fresh context: the run context may already be cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, _ = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(cfg.Bucket),
+		Key:      aws.String(cfg.Key),
+		UploadId: uploadID,
+	})
+}
+
+// uploadMetadata returns the user metadata attached to uploaded objects.
+// Generated payloads record their seed (and compressibility, if any) so the
+// content can be reproduced later.
+func uploadMetadata(cfg *Config) map[string]string {
+	meta := map[string]string{metaWriter: cfg.Mode}
+	if cfg.UploadFile != "" {
+		return meta
+	}
+	meta[metaSeed] = strconv.FormatInt(cfg.Seed, 10)
+	if cfg.Compressibility > 1 {
+		meta[metaCompress] = strconv.FormatFloat(cfg.Compressibility, 'f', -1, 64)
+	}
+	return meta
+}
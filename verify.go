@@ -0,0 +1,461 @@
+// Copyright (c) 2026 Darren Soothill <darren [at] soothill [dot] com>
+// All rights reserved.
+// Use of this source code is governed by the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Verification methods, in order of preference. The pattern check is exact per
+// range; the CRCs are checked per object by combining per-chunk CRCs; SHA256
+// and MD5 need the whole object in order and so are computed from --output.
+const (
+	verifyPattern   = "pattern"
+	verifyCRC64NVME = "crc64nvme"
+	verifyCRC32C    = "crc32c"
+	verifyCRC32     = "crc32"
+	verifySHA256    = "sha256"
+	verifyMD5       = "md5"
+	verifyNone      = "none"
+)
+
+// Outcomes of verifying one object.
+const (
+	verifyOK         = "ok"
+	verifyMismatch   = "mismatch"
+	verifyIncomplete = "incomplete" // not every range was read successfully
+	verifySkipped    = "skipped"
+)
+
+// crc64NVMEPoly is the reversed CRC-64/NVME polynomial used by S3's CRC64NVME checksum.
+const crc64NVMEPoly = 0x9A6C9329AC4BC9B5
+
+var (
+	crc32cTable    = crc32.MakeTable(crc32.Castagnoli)
+	crc64NVMETable = crc64.MakeTable(crc64NVMEPoly)
+)
+
+// objectCheck is how one object will be verified.
+type objectCheck struct {
+	Method   string
+	Seed     int64   // pattern only
+	Compress float64 // pattern only
+	Expected []byte  // checksum or digest, big-endian for CRCs
+	Reason   string  // why the object cannot be verified (verifyNone)
+}
+
+// verifyPlan maps object keys to their checks.
+type verifyPlan map[string]*objectCheck
+
+// planVerification works out how each object can be verified, using one HEAD
+// with checksum mode enabled per object. It is not timed.
+func planVerification(ctx context.Context, client *s3.Client, bucket string, objects []ObjectSpec) (verifyPlan, error) {
+	plan := make(verifyPlan, len(objects))
+	for _, obj := range objects {
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket:       aws.String(bucket),
+			Key:          aws.String(obj.Key),
+			ChecksumMode: types.ChecksumModeEnabled,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("HeadObject %s: %w", obj.Key, err)
+		}
+		plan[obj.Key] = chooseCheck(head)
+	}
+	return plan, nil
+}
+
+// chooseCheck picks the best available verification method for an object.
+// Composite checksums of multipart uploads ("<checksum>-<parts>") cannot be
+// rebuilt from arbitrary ranges and are skipped, as are multipart ETags and
+// the ETags of objects encrypted with SSE-KMS or SSE-C.
+func chooseCheck(head *s3.HeadObjectOutput) *objectCheck {
+	if v, ok := head.Metadata[metaSeed]; ok {
+		if seed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			check := &objectCheck{Method: verifyPattern, Seed: seed}
+			if c, err := strconv.ParseFloat(head.Metadata[metaCompress], 64); err == nil {
+				check.Compress = c
+			}
+			return check
+		}
+	}
+
+	for _, c := range []struct {
+		method string
+		value  *string
+	}{
+		{verifyCRC64NVME, head.ChecksumCRC64NVME},
+		{verifyCRC32C, head.ChecksumCRC32C},
+		{verifyCRC32, head.ChecksumCRC32},
+		{verifySHA256, head.ChecksumSHA256},
+	} {
+		v := aws.ToString(c.value)
+		if v == "" || strings.Contains(v, "-") || head.ChecksumType == types.ChecksumTypeComposite {
+			continue
+		}
+		if sum, err := base64.StdEncoding.DecodeString(v); err == nil {
+			return &objectCheck{Method: c.method, Expected: sum}
+		}
+	}
+
+	// The ETag of an SSE-KMS or SSE-C object is not the MD5 of its contents.
+	switch {
+	case head.ServerSideEncryption == types.ServerSideEncryptionAwsKms,
+		head.ServerSideEncryption == types.ServerSideEncryptionAwsKmsDsse:
+		return &objectCheck{Method: verifyNone, Reason: "no s3bench pattern or full-object checksum, and the ETag of an SSE-KMS object is not its MD5"}
+	case aws.ToString(head.SSECustomerAlgorithm) != "":
+		return &objectCheck{Method: verifyNone, Reason: "no s3bench pattern or full-object checksum, and the ETag of an SSE-C object is not its MD5"}
+	}
+
+	etag := strings.Trim(aws.ToString(head.ETag), `"`)
+	if len(etag) == 32 {
+		if sum, err := hex.DecodeString(etag); err == nil {
+			return &objectCheck{Method: verifyMD5, Expected: sum}
+		}
+	}
+
+	return &objectCheck{Method: verifyNone, Reason: "no s3bench pattern, full-object checksum or single-part ETag"}
+}
+
+// IntegrityError reports a chunk whose bytes did not match what was expected.
+// It is never retried: wrong data is the finding, not a transient fault.
+type IntegrityError struct {
+	Key        string
+	Method     string
+	RangeStart int64
+	RangeEnd   int64
+	Offset     int64 // first differing byte in the object, -1 if unknown
+	Detail     string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check (%s) failed for %s range %d-%d: %s",
+		e.Method, e.Key, e.RangeStart, e.RangeEnd, e.Detail)
+}
+
+// chunkChecker inspects a chunk's bytes as they stream through downloadChunk.
+type chunkChecker struct {
+	method  string
+	pattern *patternReaderAt
+	off     int64 // object offset of the next byte (pattern)
+	want    []byte
+	badAt   int64
+	crc32   hash.Hash32
+	crc64   hash.Hash64
+}
+
+// newChunkChecker returns a checker for the chunk, or nil if its object is
+// verified only as a whole (SHA256, MD5) or not at all.
+func newChunkChecker(check *objectCheck, chunk ChunkSpec) *chunkChecker {
+	c := &chunkChecker{method: check.Method, off: chunk.RangeStart, badAt: -1}
+	switch check.Method {
+	case verifyPattern:
+		c.pattern = newPatternReader(check.Seed, chunk.RangeEnd+1, check.Compress)
+	case verifyCRC32:
+		c.crc32 = crc32.NewIEEE()
+	case verifyCRC32C:
+		c.crc32 = crc32.New(crc32cTable)
+	case verifyCRC64NVME:
+		c.crc64 = crc64.New(crc64NVMETable)
+	default:
+		return nil
+	}
+	return c
+}
+
+func (c *chunkChecker) Write(p []byte) (int, error) {
+	switch {
+	case c.pattern != nil:
+		if c.badAt < 0 {
+			if cap(c.want) < len(p) {
+				c.want = make([]byte, len(p))
+			}
+			want := c.want[:len(p)]
+			n, _ := c.pattern.ReadAt(want, c.off)
+			if i := firstDiff(p, want[:n]); i >= 0 {
+				c.badAt = c.off + int64(i)
+			}
+		}
+		c.off += int64(len(p))
+	case c.crc32 != nil:
+		c.crc32.Write(p)
+	case c.crc64 != nil:
+		c.crc64.Write(p)
+	}
+	return len(p), nil
+}
+
+// firstDiff returns the index of the first byte where got differs from want,
+// treating bytes beyond want as different, or -1 if they match.
+func firstDiff(got, want []byte) int {
+	if bytes.Equal(got, want) {
+		return -1
+	}
+	for i := range got {
+		if i >= len(want) || got[i] != want[i] {
+			return i
+		}
+	}
+	return len(got)
+}
+
+// finish checks the chunk once its body has been read and returns its CRC
+// (zero for the pattern check) or an IntegrityError.
+func (c *chunkChecker) finish(key string, chunk ChunkSpec, n int64) (uint64, error) {
+	mismatch := func(offset int64, detail string) error {
+		return &IntegrityError{Key: key, Method: c.method, RangeStart: chunk.RangeStart,
+			RangeEnd: chunk.RangeEnd, Offset: offset, Detail: detail}
+	}
+	if n != chunk.Size {
+		return 0, mismatch(-1, fmt.Sprintf("received %d bytes, expected %d", n, chunk.Size))
+	}
+	if c.badAt >= 0 {
+		return 0, mismatch(c.badAt, fmt.Sprintf("data differs from the s3bench pattern at offset %d", c.badAt))
+	}
+	switch {
+	case c.crc32 != nil:
+		return uint64(c.crc32.Sum32()), nil
+	case c.crc64 != nil:
+		return c.crc64.Sum64(), nil
+	}
+	return 0, nil
+}
+
+// VerifyStats reports the outcome of --verify for one run.
+type VerifyStats struct {
+	ChunksVerified  int                  `json:"chunks_verified"`
+	RangeMismatches []RangeMismatch      `json:"range_mismatches,omitempty"`
+	Objects         []ObjectVerification `json:"objects"`
+}
+
+// RangeMismatch is one chunk that failed its integrity check.
+type RangeMismatch struct {
+	Key        string `json:"key"`
+	RangeStart int64  `json:"range_start"`
+	RangeEnd   int64  `json:"range_end"`
+	Offset     int64  `json:"first_bad_offset"` // -1 if unknown
+	Detail     string `json:"detail"`
+}
+
+// ObjectVerification is the verdict for one object.
+type ObjectVerification struct {
+	Key    string `json:"key"`
+	Method string `json:"method"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// verifyRun turns the per-chunk checks of a run into per-object verdicts.
+// Objects checked by CRC are verified by combining their chunk CRCs in offset
+// order; the first read of each range is used, and any later read of the same
+// range (with --duration) must produce the same CRC. SHA256 and MD5 digests
+// are computed by re-reading out, so need --output.
+func verifyRun(result DownloadResult, chunks []ChunkSpec, objects []ObjectSpec, plan verifyPlan, out *os.File) *VerifyStats {
+	stats := &VerifyStats{}
+	crcs := map[int]uint64{}
+	bad := map[string]int{}
+	failed := map[string]bool{}
+
+	for _, c := range result.Chunks {
+		var ie *IntegrityError
+		switch {
+		case errors.As(c.Err, &ie):
+			stats.RangeMismatches = append(stats.RangeMismatches, RangeMismatch{
+				Key: ie.Key, RangeStart: ie.RangeStart, RangeEnd: ie.RangeEnd, Offset: ie.Offset, Detail: ie.Detail,
+			})
+			bad[ie.Key]++
+		case c.Err != nil:
+			failed[c.Key] = true
+		case c.Verified:
+			stats.ChunksVerified++
+			if first, seen := crcs[c.Index]; !seen {
+				crcs[c.Index] = c.CRC
+			} else if first != c.CRC {
+				spec := chunks[c.Index]
+				stats.RangeMismatches = append(stats.RangeMismatches, RangeMismatch{
+					Key: c.Key, RangeStart: spec.RangeStart, RangeEnd: spec.RangeEnd, Offset: -1,
+					Detail: "CRC differs from an earlier read of the same range",
+				})
+				bad[c.Key]++
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		check := plan[obj.Key]
+		v := ObjectVerification{Key: obj.Key, Method: check.Method}
+		switch {
+		case bad[obj.Key] > 0:
+			v.Status = verifyMismatch
+			v.Detail = fmt.Sprintf("%d ranges did not match", bad[obj.Key])
+		case check.Method == verifyNone:
+			v.Status = verifySkipped
+			v.Detail = check.Reason
+		case failed[obj.Key]:
+			v.Status = verifyIncomplete
+			v.Detail = "some ranges failed to download"
+		case check.Method == verifyPattern:
+			v.Status = verifyOK
+		case check.Method == verifySHA256 || check.Method == verifyMD5:
+			v.Status, v.Detail = verifyDigest(check, obj, out)
+		default:
+			v.Status, v.Detail = verifyCRC(check, obj, chunks, crcs)
+		}
+		stats.Objects = append(stats.Objects, v)
+	}
+	return stats
+}
+
+// verifyCRC combines the per-chunk CRCs of an object and compares the result
+// with its full-object checksum.
+func verifyCRC(check *objectCheck, obj ObjectSpec, chunks []ChunkSpec, crcs map[int]uint64) (string, string) {
+	poly, width := uint64(crc32.IEEE), 32
+	switch check.Method {
+	case verifyCRC32C:
+		poly = crc32.Castagnoli
+	case verifyCRC64NVME:
+		poly, width = crc64NVMEPoly, 64
+	}
+
+	var sum uint64
+	first := true
+	for _, c := range chunks {
+		if c.Key != obj.Key {
+			continue
+		}
+		crc, ok := crcs[c.Index]
+		if !ok {
+			return verifyIncomplete, "not every range was read during the run"
+		}
+		if first {
+			sum, first = crc, false
+		} else {
+			sum = crcCombine(sum, crc, c.Size, poly, width)
+		}
+	}
+
+	got := binary.BigEndian.AppendUint64(nil, sum)[8-width/8:]
+	if !bytes.Equal(got, check.Expected) {
+		return verifyMismatch, fmt.Sprintf("object %s is %x, expected %x", check.Method, got, check.Expected)
+	}
+	return verifyOK, ""
+}
+
+// verifyDigest re-reads the downloaded object from the output file and
+// compares its SHA256 or MD5 digest.
+func verifyDigest(check *objectCheck, obj ObjectSpec, out *os.File) (string, string) {
+	if out == nil {
+		return verifySkipped, check.Method + " needs the whole object in order; use --output"
+	}
+	h := md5.New()
+	if check.Method == verifySHA256 {
+		h = sha256.New()
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(out, 0, obj.Size)); err != nil {
+		return verifyIncomplete, fmt.Sprintf("re-reading output file: %v", err)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, check.Expected) {
+		return verifyMismatch, fmt.Sprintf("object %s is %x, expected %x", check.Method, got, check.Expected)
+	}
+	return verifyOK, ""
+}
+
+// verifyDisplay summarises the verification methods for the header.
+func verifyDisplay(plan verifyPlan, objects []ObjectSpec) string {
+	counts := map[string]int{}
+	for _, obj := range objects {
+		counts[plan[obj.Key].Method]++
+	}
+	if len(objects) == 1 {
+		check := plan[objects[0].Key]
+		if check.Method == verifyNone {
+			return "not possible (" + check.Reason + ")"
+		}
+		return check.Method
+	}
+	var parts []string
+	for _, m := range []string{verifyPattern, verifyCRC64NVME, verifyCRC32C, verifyCRC32, verifySHA256, verifyMD5, verifyNone} {
+		if counts[m] > 0 {
+			parts = append(parts, fmt.Sprintf("%s ×%d", m, counts[m]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// crcCombine returns the CRC of A followed by B given the CRCs of each and the
+// length of B, for a reflected CRC of the given width whose reversed polynomial
+// is poly. It is zlib's crc32_combine generalised to 64 bits: appending lenB
+// zero bytes to A is a linear operator, applied by repeated matrix squaring.
+func crcCombine(crcA, crcB uint64, lenB int64, poly uint64, width int) uint64 {
+	if lenB == 0 {
+		return crcA
+	}
+	odd := make([]uint64, width) // operator for one zero bit
+	even := make([]uint64, width)
+	odd[0] = poly
+	row := uint64(1)
+	for n := 1; n < width; n++ {
+		odd[n] = row
+		row <<= 1
+	}
+	gf2MatrixSquare(even, odd) // two zero bits
+	gf2MatrixSquare(odd, even) // four zero bits
+
+	// Apply len(B) zero bytes to crcA, one bit of lenB per squaring.
+	for {
+		gf2MatrixSquare(even, odd)
+		if lenB&1 != 0 {
+			crcA = gf2MatrixTimes(even, crcA)
+		}
+		lenB >>= 1
+		if lenB == 0 {
+			break
+		}
+		gf2MatrixSquare(odd, even)
+		if lenB&1 != 0 {
+			crcA = gf2MatrixTimes(odd, crcA)
+		}
+		lenB >>= 1
+		if lenB == 0 {
+			break
+		}
+	}
+	return crcA ^ crcB
+}
+
+func gf2MatrixTimes(mat []uint64, vec uint64) uint64 {
+	var sum uint64
+	for i := 0; vec != 0; i, vec = i+1, vec>>1 {
+		if vec&1 != 0 {
+			sum ^= mat[i]
+		}
+	}
+	return sum
+}
+
+func gf2MatrixSquare(square, mat []uint64) {
+	for n := range mat {
+		square[n] = gf2MatrixTimes(mat, mat[n])
+	}
+}